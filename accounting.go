@@ -0,0 +1,237 @@
+package gomrjob
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+var records sync.Map // "in <protocol>" or "out <protocol>" -> *atomic.Int64
+
+// RecordsIn returns the count of records read by protocol in this task. The
+// total is reported as a counter when the task exits.
+func RecordsIn(protocol string) *atomic.Int64 {
+	return recordCounter("in", protocol)
+}
+
+// RecordsOut returns the count of records written by protocol in this task.
+// The total is reported as a counter when the task exits.
+func RecordsOut(protocol string) *atomic.Int64 {
+	return recordCounter("out", protocol)
+}
+
+func recordCounter(direction, protocol string) *atomic.Int64 {
+	key := direction + " " + protocol
+	if c, ok := records.Load(key); ok {
+		return c.(*atomic.Int64)
+	}
+	c, _ := records.LoadOrStore(key, new(atomic.Int64))
+	return c.(*atomic.Int64)
+}
+
+// auditResources records counters for the resources used by this task. Each
+// counter is prefixed with "<stage>[<step>]" so the submitter can aggregate
+// them per stage with StepResources. Unless detailed is set only tasks,
+// wallTime, userTime and maxRSS are reported, as hadoop limits the number of
+// counters in a job (mapreduce.job.counters.max).
+func auditResources(group string, prefix string, start time.Time, detailed bool) {
+	counter := func(name string, v int64) {
+		Counter(group, fmt.Sprintf("%s %s", prefix, name), v)
+	}
+	counter("tasks", 1)
+	counter("wallTime (ms)", time.Since(start).Milliseconds())
+
+	var u syscall.Rusage
+	err := syscall.Getrusage(syscall.RUSAGE_SELF, &u)
+	if err != nil {
+		log.Printf("error getting Rusage: %s", err)
+	} else {
+		counter("userTime (ms)", time.Duration(u.Utime.Nano()).Milliseconds())
+		maxRSS := int64(u.Maxrss)
+		if runtime.GOOS != "darwin" {
+			maxRSS *= 1024 // linux reports KiB, darwin bytes
+		}
+		counter("maxRSS (bytes)", maxRSS)
+	}
+	if !detailed {
+		return
+	}
+
+	if err == nil {
+		counter("systemTime (ms)", time.Duration(u.Stime.Nano()).Milliseconds())
+		counter("majorFaults", int64(u.Majflt))
+		counter("minorFaults", int64(u.Minflt))
+		counter("voluntaryCtxSwitches", int64(u.Nvcsw))
+		counter("involuntaryCtxSwitches", int64(u.Nivcsw))
+	}
+	read, write, ok := procIO()
+	if !ok {
+		// only blocks read from or written to disk (not pipes or the page cache)
+		read, write = int64(u.Inblock)*512, int64(u.Oublock)*512
+	}
+	counter("readBytes", read)
+	counter("writeBytes", write)
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	counter("gcCycles", int64(m.NumGC))
+	counter("gcPause (ms)", time.Duration(m.PauseTotalNs).Milliseconds())
+	counter("totalAlloc (bytes)", int64(m.TotalAlloc))
+	counter("heapSys (bytes)", int64(m.HeapSys))
+
+	records.Range(func(key, value any) bool {
+		counter("records "+key.(string), value.(*atomic.Int64).Load())
+		return true
+	})
+}
+
+// procIO returns the bytes read and written by this process (including
+// stdin, stdout and network I/O) from /proc/self/io, which is linux only
+func procIO() (read, write int64, ok bool) {
+	b, err := os.ReadFile("/proc/self/io")
+	if err != nil {
+		return 0, 0, false
+	}
+	var haveRead, haveWrite bool
+	for _, line := range strings.Split(string(b), "\n") {
+		k, v, _ := strings.Cut(line, ": ")
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case "rchar":
+			read, haveRead = n, true
+		case "wchar":
+			write, haveWrite = n, true
+		}
+	}
+	return read, write, haveRead && haveWrite
+}
+
+// StageResources totals the resources used by the tasks of one stage of a
+// step. Per task averages are available by dividing by Tasks.
+type StageResources struct {
+	Tasks                  int64
+	WallTime               time.Duration
+	UserTime               time.Duration
+	SystemTime             time.Duration
+	MaxRSS                 int64 // the sum of the peak RSS of each task
+	MajorFaults            int64
+	MinorFaults            int64
+	ReadBytes              int64 // bytes read by read(2) and similar; on non-linux systems only blocks read from disk
+	WriteBytes             int64 // bytes written by write(2) and similar; on non-linux systems only blocks written to disk
+	VoluntaryCtxSwitches   int64
+	InvoluntaryCtxSwitches int64
+	GCCycles               int64
+	GCPause                time.Duration
+	TotalAlloc             int64            // bytes allocated for heap objects
+	HeapSys                int64            // the sum of heap memory obtained from the OS by each task
+	RecordsIn              map[string]int64 // by protocol
+	RecordsOut             map[string]int64 // by protocol
+}
+
+// AvgMaxRSS is the average peak RSS of each task in bytes
+func (s StageResources) AvgMaxRSS() int64 {
+	if s.Tasks == 0 {
+		return 0
+	}
+	return s.MaxRSS / s.Tasks
+}
+
+func (s StageResources) String() string {
+	if s.Tasks == 0 {
+		return "0 tasks"
+	}
+	avg := func(d time.Duration) time.Duration {
+		return (d / time.Duration(s.Tasks)).Round(time.Millisecond)
+	}
+	o := fmt.Sprintf("%d tasks avg wall:%s user:%s sys:%s maxRSS:%s gc:%d pause:%s majflt:%d read:%s write:%s",
+		s.Tasks, avg(s.WallTime), avg(s.UserTime), avg(s.SystemTime), formatBytes(s.AvgMaxRSS()),
+		s.GCCycles/s.Tasks, avg(s.GCPause), s.MajorFaults/s.Tasks,
+		formatBytes(s.ReadBytes/s.Tasks), formatBytes(s.WriteBytes/s.Tasks))
+	for _, m := range []struct {
+		dir string
+		r   map[string]int64
+	}{{"in", s.RecordsIn}, {"out", s.RecordsOut}} {
+		var protocols []string
+		for p := range m.r {
+			protocols = append(protocols, p)
+		}
+		sort.Strings(protocols)
+		for _, p := range protocols {
+			o += fmt.Sprintf(" %s %s:%d", m.dir, p, m.r[p])
+		}
+	}
+	return o
+}
+
+var resourceCounterRe = regexp.MustCompile(`^(\w+)\[(\d+)\] (.+)$`)
+
+// StepResources aggregates the resource counters for a step by stage
+// ("mapper", "combiner", "reducer")
+func StepResources(c hdfs.Counters, step int) map[string]*StageResources {
+	o := make(map[string]*StageResources)
+	for name, v := range c["gomrjob"] {
+		m := resourceCounterRe.FindStringSubmatch(name)
+		if m == nil || m[2] != fmt.Sprint(step) {
+			continue
+		}
+		s, ok := o[m[1]]
+		if !ok {
+			s = &StageResources{RecordsIn: make(map[string]int64), RecordsOut: make(map[string]int64)}
+			o[m[1]] = s
+		}
+		ms := time.Duration(v) * time.Millisecond
+		switch m[3] {
+		case "tasks":
+			s.Tasks = v
+		case "wallTime (ms)":
+			s.WallTime = ms
+		case "userTime (ms)":
+			s.UserTime = ms
+		case "systemTime (ms)":
+			s.SystemTime = ms
+		case "maxRSS (bytes)":
+			s.MaxRSS = v
+		case "majorFaults":
+			s.MajorFaults = v
+		case "minorFaults":
+			s.MinorFaults = v
+		case "readBytes":
+			s.ReadBytes = v
+		case "writeBytes":
+			s.WriteBytes = v
+		case "voluntaryCtxSwitches":
+			s.VoluntaryCtxSwitches = v
+		case "involuntaryCtxSwitches":
+			s.InvoluntaryCtxSwitches = v
+		case "gcCycles":
+			s.GCCycles = v
+		case "gcPause (ms)":
+			s.GCPause = ms
+		case "totalAlloc (bytes)":
+			s.TotalAlloc = v
+		case "heapSys (bytes)":
+			s.HeapSys = v
+		default:
+			if p, ok := strings.CutPrefix(m[3], "records in "); ok {
+				s.RecordsIn[p] = v
+			} else if p, ok := strings.CutPrefix(m[3], "records out "); ok {
+				s.RecordsOut[p] = v
+			}
+		}
+	}
+	return o
+}
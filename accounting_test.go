@@ -0,0 +1,69 @@
+package gomrjob
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepResources(t *testing.T) {
+	c := hdfs.Counters{
+		"gomrjob": {
+			"mapper[1] tasks":                                  4,
+			"mapper[1] wallTime (ms)":                          8000,
+			"mapper[1] userTime (ms)":                          4000,
+			"mapper[1] maxRSS (bytes)":                         4 * 1024 * 1024,
+			"mapper[1] gcCycles":                               12,
+			"mapper[1] totalAlloc (bytes)":                     1 << 20,
+			"mapper[1] readBytes":                              8 << 10,
+			"mapper[1] records in RawInputProtocol":            100,
+			"mapper[1] records out JsonInternalOutputProtocol": 90,
+			"reducer[1] tasks":                                 1,
+			"mapper[0] tasks":                                  3,
+			"remote log lines dropped":                         1,
+		},
+		"app": {"reducer[1] tasks": 5},
+	}
+	r := StepResources(c, 1)
+	assert.Equal(t, len(r), 2)
+	m := r["mapper"]
+	assert.Equal(t, m.Tasks, int64(4))
+	assert.Equal(t, m.WallTime, 8*time.Second)
+	assert.Equal(t, m.UserTime, 4*time.Second)
+	assert.Equal(t, m.AvgMaxRSS(), int64(1024*1024))
+	assert.Equal(t, m.TotalAlloc, int64(1<<20))
+	assert.Equal(t, m.RecordsIn, map[string]int64{"RawInputProtocol": 100})
+	assert.Equal(t, m.RecordsOut, map[string]int64{"JsonInternalOutputProtocol": 90})
+	assert.Equal(t, m.String(), "4 tasks avg wall:2s user:1s sys:0s maxRSS:1.0 MiB gc:3 pause:0s majflt:0 read:2.0 KiB write:0 B in RawInputProtocol:100 out JsonInternalOutputProtocol:90")
+	assert.Equal(t, r["reducer"].Tasks, int64(1))
+	assert.Equal(t, StageResources{}.String(), "0 tasks")
+}
+
+func TestProcIO(t *testing.T) {
+	read, _, ok := procIO()
+	if !ok {
+		t.Skip("/proc/self/io is not available")
+	}
+	os.ReadFile("accounting.go") // nolint:errcheck
+	after, _, _ := procIO()
+	assert.True(t, after > read, "%d > %d", after, read)
+}
+
+func TestAuditResources(t *testing.T) {
+	var out bytes.Buffer
+	defer SetReporterOutput(SetReporterOutput(&out))
+
+	auditResources("gomrjob", "mapper[0]", time.Now(), false)
+	assert.Equal(t, strings.Count(out.String(), "reporter:counter:"), 4)
+	assert.NotContains(t, out.String(), "gcCycles")
+	out.Reset()
+
+	auditResources("gomrjob", "mapper[0]", time.Now(), true)
+	assert.Contains(t, out.String(), "reporter:counter:gomrjob,mapper[0] gcCycles,")
+	assert.Contains(t, out.String(), "reporter:counter:gomrjob,mapper[0] readBytes,")
+}
@@ -0,0 +1,22 @@
+package gomrjob
+
+import (
+	"context"
+
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+// Backend runs the jobs of a Runner in place of HDFS or Dataproc (per
+// JobType), i.e. a fake cluster in tests. When Runner.Backend is set Run does
+// not require --submit-job and remote logging defaults to disabled.
+type Backend interface {
+	// DefaultProto prefixes paths without a scheme, i.e. "file:///tmp/cluster/"
+	DefaultProto() string
+
+	// Stage copies a local file to remote (a path relative to DefaultProto) so
+	// that it can be used as a cache file by tasks
+	Stage(ctx context.Context, local, remote string) error
+
+	// Submit runs the job for step and returns once it has completed
+	Submit(ctx context.Context, j hdfs.Job, step Step) error
+}
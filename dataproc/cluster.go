@@ -0,0 +1,71 @@
+package dataproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// https://cloud.google.com/dataproc/docs/reference/rest/v1/projects.regions.clusters#Cluster
+type clusterResource struct {
+	Config struct {
+		WorkerConfig          instanceGroupConfig `json:"workerConfig"`
+		SecondaryWorkerConfig instanceGroupConfig `json:"secondaryWorkerConfig"`
+	} `json:"config"`
+}
+
+// https://cloud.google.com/dataproc/docs/reference/rest/v1/ClusterConfig#InstanceGroupConfig
+type instanceGroupConfig struct {
+	NumInstances   int    `json:"numInstances"`
+	MachineTypeURI string `json:"machineTypeUri"`
+}
+
+func (c instanceGroupConfig) vCPUs() int {
+	return c.NumInstances * machineTypeVCPUs(c.MachineTypeURI)
+}
+
+// ClusterVCPUs returns the total vCPUs across the primary and secondary workers of a cluster
+func ClusterVCPUs(client *http.Client, project, region, cluster string) (int, error) {
+	resource := fmt.Sprintf("%s/v1/projects/%s/regions/%s/clusters/%s", APIBase, url.PathEscape(project), url.PathEscape(region), url.PathEscape(cluster))
+	resp, err := client.Get(resource)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != 200 {
+		log.Print(string(respBody))
+		return 0, fmt.Errorf("got status code %d", resp.StatusCode)
+	}
+	var c clusterResource
+	if err := json.Unmarshal(respBody, &c); err != nil {
+		return 0, err
+	}
+	return c.Config.WorkerConfig.vCPUs() + c.Config.SecondaryWorkerConfig.vCPUs(), nil
+}
+
+// machineTypeVCPUs parses the vCPU count from a Compute Engine machine type
+// (i.e. "n1-standard-8" or "custom-6-23040"), defaulting to 1 if unknown
+func machineTypeVCPUs(uri string) int {
+	chunks := strings.Split(path.Base(uri), "-")
+	vcpus := chunks[len(chunks)-1]
+	for i, c := range chunks {
+		if c == "custom" && i+1 < len(chunks) {
+			vcpus = chunks[i+1]
+		}
+	}
+	n, err := strconv.Atoi(vcpus)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,26 @@
+package dataproc
+
+import (
+	"testing"
+)
+
+func TestMachineTypeVCPUs(t *testing.T) {
+	type testCase struct {
+		uri    string
+		expect int
+	}
+	tests := []testCase{
+		{"https://www.googleapis.com/compute/v1/projects/p/zones/us-east1-b/machineTypes/n1-standard-8", 8},
+		{"n2-highmem-16", 16},
+		{"custom-6-23040", 6},
+		{"n2-custom-4-16384", 4},
+		{"e2-micro", 1},
+		{"", 1},
+	}
+	for i, tc := range tests {
+		got := machineTypeVCPUs(tc.uri)
+		if got != tc.expect {
+			t.Errorf("test[%d] got %d expected %d for %q", i, got, tc.expect, tc.uri)
+		}
+	}
+}
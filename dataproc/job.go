@@ -2,16 +2,28 @@ package dataproc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/jehiah/gomrjob/internal/storage"
+)
+
+// APIBase is the Dataproc API endpoint; it can be changed to use a fake (see gcpfake)
+var APIBase = "https://dataproc.googleapis.com"
+
+var (
+	PollInterval  = 2 * time.Second  // how often SubmitJob checks job status
+	RetryInterval = 10 * time.Second // delay before retrying a request that failed with 503
 )
 
 func isErrorState(s string) bool {
@@ -51,12 +63,47 @@ type job struct {
 		FileURIs       []string          `json:"fileUris,omitempty"`
 		Properties     map[string]string `json:"properties,omitempty"`
 	} `json:"hadoopJob"`
-	Status struct {
+	DriverOutputResourceURI string `json:"driverOutputResourceUri,omitempty"`
+	Status                  struct {
 		State          string `json:"state,omitempty"`
 		StateStartTime string `json:"stateStartTime,omitempty"`
 		Details        string `json:"details,omitempty"`
 		SubState       string `json:"substate,omitempty"`
 	} `json:"status,omitempty"`
+	// https://cloud.google.com/dataproc/docs/reference/rest/v1/projects.regions.jobs#YarnApplication
+	YarnApplications []struct {
+		Name        string  `json:"name"`
+		State       string  `json:"state"`
+		Progress    float64 `json:"progress"`
+		TrackingURL string  `json:"trackingUrl"`
+	} `json:"yarnApplications,omitempty"`
+}
+
+// progress returns the job progress; Dataproc reports only the overall
+// progress of each YARN application, so Map and Reduce are unset.
+func (j *job) progress(start time.Time) hdfs.Progress {
+	p := hdfs.Progress{
+		JobID:   j.Reference.JobID,
+		State:   j.Status.State,
+		Elapsed: time.Since(start),
+	}
+	for _, app := range j.YarnApplications {
+		p.Overall = app.Progress * 100
+		if app.State == "RUNNING" {
+			p.State = app.State
+		}
+	}
+	return p
+}
+
+// runningTrackingURL returns the tracking url of the running YARN application
+func (j *job) runningTrackingURL() string {
+	for _, app := range j.YarnApplications {
+		if app.State == "RUNNING" {
+			return app.TrackingURL
+		}
+	}
+	return ""
 }
 
 func SubmitJob(j hdfs.Job, client *http.Client, project, region, cluster string) error {
@@ -82,16 +129,19 @@ func SubmitJob(j hdfs.Job, client *http.Client, project, region, cluster string)
 	req.Job.HadoopJob.FileURIs = j.CacheFiles
 	req.Job.HadoopJob.Properties = p
 
-	resource := fmt.Sprintf("https://dataproc.googleapis.com/v1/projects/%s/regions/%s/jobs:submit", url.PathEscape(project), url.PathEscape(region))
+	resource := fmt.Sprintf("%s/v1/projects/%s/regions/%s/jobs:submit", APIBase, url.PathEscape(project), url.PathEscape(region))
 	job, err := post(client, resource, req)
 	if err != nil {
 		return err
 	}
 	state := job.Status.State
 	log.Printf("job:%s status:%s", job.Reference.JobID, state)
+	start := time.Now()
+	var last hdfs.Progress
+	var yarnFailed bool // the ResourceManager could not be queried for running tasks
 
-	resource = fmt.Sprintf("https://dataproc.googleapis.com/v1/projects/%s/regions/%s/jobs/%s", url.PathEscape(project), url.PathEscape(region), url.PathEscape(job.Reference.JobID))
-	ticker := time.NewTicker(time.Second * 2)
+	resource = jobResource(project, region, job.Reference.JobID)
+	ticker := time.NewTicker(PollInterval)
 	defer ticker.Stop()
 	var i int
 	for range ticker.C {
@@ -100,12 +150,39 @@ func SubmitJob(j hdfs.Job, client *http.Client, project, region, cluster string)
 		if err != nil {
 			return err
 		}
-		// if state changes or 30s passes by
+		// if state changes or 15 polls (30s by default) pass by
 		if state != job.Status.State || i%15 == 0 {
 			state = job.Status.State
 			log.Printf("job:%s status:%s", job.Reference.JobID, state)
 		}
+		// the driver output has the job counters, including failed tasks
+		var counters hdfs.Counters
+		if isTerminalState(state) && job.DriverOutputResourceURI != "" && (j.OnCounters != nil || j.OnProgress != nil) {
+			counters, err = driverCounters(client, job.DriverOutputResourceURI)
+			if err != nil {
+				log.Printf("failed reading counters from %s %s", job.DriverOutputResourceURI, err)
+			}
+		}
+		if j.OnProgress != nil {
+			p := job.progress(start)
+			p.FailedTasks = counters.FailedTasks()
+			if url := job.runningTrackingURL(); url != "" && !yarnFailed {
+				// the ResourceManager is only reachable from the cluster's network
+				if p.RunningTasks, err = hdfs.RunningTasks(url); err != nil {
+					log.Printf("failed getting running tasks %s", err)
+					yarnFailed = true
+				}
+			}
+			if p.State != last.State || p.Percent() != last.Percent() || p.FailedTasks != last.FailedTasks || p.RunningTasks != last.RunningTasks {
+				j.OnProgress(p)
+				last = p
+			}
+		}
 		if isTerminalState(state) {
+			// as with hdfs.SubmitJob counters are reported for failed jobs too
+			if j.OnCounters != nil && counters != nil {
+				j.OnCounters(counters)
+			}
 			if isErrorState(state) {
 				return fmt.Errorf("job:%s finished with status:%s", job.Reference.JobID, state)
 			}
@@ -115,6 +192,36 @@ func SubmitJob(j hdfs.Job, client *http.Client, project, region, cluster string)
 	return nil
 }
 
+func jobResource(project, region, jobID string) string {
+	return fmt.Sprintf("%s/v1/projects/%s/regions/%s/jobs/%s", APIBase, url.PathEscape(project), url.PathEscape(region), url.PathEscape(jobID))
+}
+
+// CancelJob requests that a running job be cancelled; SubmitJob returns an
+// error once the job reaches the CANCELLED state
+// https://cloud.google.com/dataproc/docs/reference/rest/v1/projects.regions.jobs/cancel
+func CancelJob(client *http.Client, project, region, jobID string) error {
+	resp, err := client.Post(jobResource(project, region, jobID)+":cancel", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case 200:
+		return nil
+	case 503:
+		return unavalable503{body: string(respBody)}
+	}
+	log.Print(string(respBody))
+	return fmt.Errorf("got status code %d", resp.StatusCode)
+}
+
+// ErrUnavailable is matched (with errors.Is) by errors from a 503 response
+var ErrUnavailable = errors.New("503 Unavailable")
+
 type unavalable503 struct {
 	body string
 }
@@ -123,6 +230,8 @@ func (u unavalable503) Error() string {
 	return fmt.Sprintf("503 Unavailable %s", u.body)
 }
 
+func (u unavalable503) Unwrap() error { return ErrUnavailable }
+
 func get(client *http.Client, resource string) (*job, error) {
 	var j *job
 	var err error
@@ -133,7 +242,7 @@ func get(client *http.Client, resource string) (*job, error) {
 		}
 		if _, ok := err.(unavalable503); ok {
 			log.Printf("retrying get. err:%s", err)
-			time.Sleep(10 * time.Second)
+			time.Sleep(RetryInterval)
 		} else {
 			break
 		}
@@ -182,6 +291,9 @@ func post(client *http.Client, resource string, req jobRequest) (*job, error) {
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode == 503 {
+		return nil, unavalable503{body: string(respBody)}
+	}
 	if resp.StatusCode != 200 {
 		log.Print(string(respBody))
 		return nil, fmt.Errorf("got status code %d", resp.StatusCode)
@@ -189,3 +301,34 @@ func post(client *http.Client, resource string, req jobRequest) (*job, error) {
 	var j job
 	return &j, json.Unmarshal(respBody, &j)
 }
+
+// driverCounters parses job counters from the driver output (the output of
+// `hadoop jar`) which Dataproc stores as a series of objects prefixed by uri
+func driverCounters(client *http.Client, uri string) (hdfs.Counters, error) {
+	bucket, prefix, ok := strings.Cut(strings.TrimPrefix(uri, "gs://"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid driver output uri %q", uri)
+	}
+	ctx := context.Background()
+	var readers []io.Reader
+	var token string
+	for {
+		items, next, err := storage.List(ctx, client, bucket, prefix, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range items {
+			rc, err := storage.Open(ctx, client, bucket, obj.Name)
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			readers = append(readers, rc)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	return hdfs.ParseCounters(io.MultiReader(readers...)), nil
+}
@@ -0,0 +1,135 @@
+package dataproc_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob/dataproc"
+	"github.com/jehiah/gomrjob/gcpfake"
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+func testJob(name string) hdfs.Job {
+	return hdfs.Job{
+		Name:         name,
+		Input:        []string{"gs://bucket/input"},
+		Output:       "gs://bucket/output",
+		Mapper:       "gomrjob_binary --stage=mapper",
+		Reducer:      "gomrjob_binary --stage=reducer",
+		ReducerTasks: 3,
+		CacheFiles:   []string{"gs://bucket/tmp/gomrjob_binary#gomrjob_binary"},
+	}
+}
+
+func TestSubmitJob(t *testing.T) {
+	s := gcpfake.NewServer(t)
+	s.OnSubmit = func(j *gcpfake.Job) {
+		j.DriverOutput = "INFO mapreduce.Job: Counters: 1\n\tgomrjob\n\t\tmapper[0] tasks=2\n"
+		j.RunningTasks = 3
+	}
+	j := testJob("job-1")
+	var states []string
+	var last hdfs.Progress
+	var running []int
+	j.OnProgress = func(p hdfs.Progress) {
+		states, last, running = append(states, p.State), p, append(running, p.RunningTasks)
+	}
+	var counters hdfs.Counters
+	j.OnCounters = func(c hdfs.Counters) { counters = c }
+	if err := dataproc.SubmitJob(j, s.Client(), "p", "r", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(states, ","); got != "SETUP_DONE,RUNNING,DONE" {
+		t.Errorf("got states %s", got)
+	}
+	if last.Overall != 100 || last.Map != 0 || last.Reduce != 0 {
+		t.Errorf("got progress %#v expected only Overall 100%%", last)
+	}
+	if fmt.Sprint(running) != "[0 3 0]" {
+		t.Errorf("got running tasks %v expected [0 3 0]", running)
+	}
+	if got := counters.Get("gomrjob", "mapper[0] tasks"); got != 2 {
+		t.Errorf("got counter %d expected 2", got)
+	}
+	job, ok := s.Job("job-1")
+	if !ok {
+		t.Fatal("job-1 not submitted")
+	}
+	if job.Cluster != "c" || job.Properties["mapred.reduce.tasks"] != "3" || len(job.FileURIs) != 1 {
+		t.Errorf("unexpected job %#v", job)
+	}
+	if err := dataproc.SubmitJob(j, s.Client(), "p", "r", "c"); err == nil {
+		t.Errorf("expected error resubmitting job-1")
+	}
+}
+
+func TestSubmitJobErrors(t *testing.T) {
+	s := gcpfake.NewServer(t)
+	s.OnSubmit = func(j *gcpfake.Job) {
+		if j.ID == "job-error" {
+			j.States = []string{"RUNNING", "ERROR"}
+			j.DriverOutput = "INFO mapreduce.Job: Counters: 2\n\tJob Counters \n\t\tFailed map tasks=4\n\t\tFailed reduce tasks=1\n"
+		}
+	}
+	j := testJob("job-error")
+	var last hdfs.Progress
+	j.OnProgress = func(p hdfs.Progress) { last = p }
+	var counters hdfs.Counters
+	j.OnCounters = func(c hdfs.Counters) { counters = c }
+	err := dataproc.SubmitJob(j, s.Client(), "p", "r", "c")
+	if err == nil || !strings.Contains(err.Error(), "status:ERROR") {
+		t.Errorf("got %v expected status:ERROR", err)
+	}
+	if last.State != "ERROR" || last.FailedTasks != 5 {
+		t.Errorf("got %s with %d failed tasks expected ERROR with 5", last.State, last.FailedTasks)
+	}
+	if got := counters.Get("Job Counters", "Failed map tasks"); got != 4 {
+		t.Errorf("got counter %d expected 4 for the failed job", got)
+	}
+
+	s.FailNext(1, http.StatusServiceUnavailable)
+	err = dataproc.SubmitJob(testJob("job-503"), s.Client(), "p", "r", "c")
+	if !errors.Is(err, dataproc.ErrUnavailable) {
+		t.Errorf("got %v expected ErrUnavailable", err)
+	}
+
+	// gets are retried
+	j = testJob("job-retry")
+	j.OnProgress = func(p hdfs.Progress) {
+		if p.State == "SETUP_DONE" {
+			s.FailNext(2, http.StatusServiceUnavailable)
+		}
+	}
+	if err := dataproc.SubmitJob(j, s.Client(), "p", "r", "c"); err != nil {
+		t.Errorf("got %v expected retry", err)
+	}
+	if job, _ := s.Job("job-retry"); job.Gets != 3 {
+		t.Errorf("got %d gets expected 3", job.Gets)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	s := gcpfake.NewServer(t)
+	s.OnSubmit = func(j *gcpfake.Job) { j.States = []string{"RUNNING", "RUNNING", "RUNNING", "DONE"} }
+	j := testJob("job-cancel")
+	j.OnProgress = func(p hdfs.Progress) {
+		if p.State == "RUNNING" {
+			if err := dataproc.CancelJob(s.Client(), "p", "r", p.JobID); err != nil {
+				t.Errorf("cancel failed %s", err)
+			}
+		}
+	}
+	err := dataproc.SubmitJob(j, s.Client(), "p", "r", "c")
+	if err == nil || !strings.Contains(err.Error(), "status:CANCELLED") {
+		t.Errorf("got %v expected status:CANCELLED", err)
+	}
+	if err := dataproc.CancelJob(s.Client(), "p", "r", "job-cancel"); err == nil {
+		t.Errorf("expected error cancelling a cancelled job")
+	}
+	if err := dataproc.CancelJob(s.Client(), "p", "r", "missing"); err == nil {
+		t.Errorf("expected error cancelling a missing job")
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"os"
 
 	"github.com/jehiah/gomrjob"
-	"github.com/jehiah/gomrjob/hdfs"
 	"github.com/jehiah/gomrjob/mrproto"
 	"github.com/jehiah/lru"
 )
@@ -28,6 +27,7 @@ func (s *JsonEntryCounter) Mapper(r io.Reader, w io.Writer) error {
 		out <- mrproto.KeyValue{k, v}
 	}, 100)
 
+	linesRead := gomrjob.NewCounter("example_mr", "Map Lines Read")
 	for line := range mrproto.RawInputProtocol(r) {
 		var record map[string]json.RawMessage
 		if err := json.Unmarshal(line, &record); err != nil {
@@ -35,7 +35,7 @@ func (s *JsonEntryCounter) Mapper(r io.Reader, w io.Writer) error {
 			log.Printf("%s", err)
 			continue
 		}
-		gomrjob.Counter("example_mr", "Map Lines Read", 1)
+		linesRead.Inc()
 		counter.Incr("lines_read", 1)
 		for k, _ := range record {
 			counter.Incr(k, 1)
@@ -68,16 +68,10 @@ func main() {
 		log.Fatalf("Run error %s", err)
 	}
 
-	switch runner.JobType {
-	case gomrjob.HDFS:
-		cmd := hdfs.Cat(fmt.Sprintf("%s/part-*", runner.Output))
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
+	for line, err := range runner.OutputLines() {
+		if err != nil {
 			log.Fatal(err)
 		}
-	case gomrjob.Dataproc:
-		log.Printf("output in %s/part-*", runner.Output)
+		fmt.Printf("%s\n", line)
 	}
-
 }
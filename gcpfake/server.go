@@ -0,0 +1,488 @@
+// Package gcpfake is an in-memory fake of the parts of the Dataproc and Google
+// Storage JSON APIs used by gomrjob, for testing code that submits jobs or
+// reads and writes gs:// paths without network access.
+//
+//	s := gcpfake.NewServer(t) // points dataproc and gs:// access at the fake
+//	s.OnSubmit = func(j *gcpfake.Job) { j.States = []string{"RUNNING", "ERROR"} }
+//	err := dataproc.SubmitJob(job, s.Client(), "project", "region", "cluster")
+package gcpfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jehiah/gomrjob/dataproc"
+	"github.com/jehiah/gomrjob/internal/storage"
+	"github.com/jehiah/gomrjob/mrfs"
+)
+
+// DefaultStates are the states a job moves through after it is submitted, one
+// each time it is fetched
+var DefaultStates = []string{"SETUP_DONE", "RUNNING", "DONE"}
+
+// Job is a job submitted to the fake Dataproc API
+type Job struct {
+	Project    string
+	Region     string
+	Cluster    string
+	ID         string
+	Args       []string
+	FileURIs   []string
+	Properties map[string]string
+
+	// State is the current state; it is PENDING when submitted and then
+	// advances through States as the job is fetched
+	State  string
+	States []string
+
+	// DriverOutput is written to the driver output of a job when it finishes
+	// (i.e. the counters logged by hadoop; see hdfs.ParseCounters)
+	DriverOutput string
+
+	// RunningTasks is reported by the YARN ResourceManager API (at the
+	// tracking url of the job) while the job is RUNNING
+	RunningTasks int
+
+	Gets int // the number of times the job has been fetched
+}
+
+func (j *Job) driverOutputURI(bucket string) string {
+	return fmt.Sprintf("gs://%s/google-cloud-dataproc-metainfo/jobs/%s/driveroutput", bucket, j.ID)
+}
+
+// Server is a fake Dataproc and Google Storage API server
+type Server struct {
+	*httptest.Server
+
+	// OnSubmit is called as each job is submitted and may change its States
+	// or DriverOutput
+	OnSubmit func(j *Job)
+
+	// Bucket stores driver output; it defaults to "dataproc-staging"
+	Bucket string
+
+	// PageSize limits the number of objects returned by each list request
+	PageSize int
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	order   []string
+	objects map[string]map[string][]byte
+	fail    []int
+}
+
+// NewServer starts a Server and, until the test completes, points the dataproc
+// package (with millisecond polling and retry intervals) and gs:// paths in
+// mrfs at it. These are package globals, so NewServer can't be used from
+// parallel tests; a second Server replaces the first until its test completes.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		Bucket:   "dataproc-staging",
+		PageSize: 1000,
+		jobs:     make(map[string]*Job),
+		objects:  make(map[string]map[string][]byte),
+	}
+	s.Server = httptest.NewServer(s.handler())
+	t.Cleanup(s.Close)
+
+	dataprocBase, storageBase := dataproc.APIBase, storage.APIBase
+	poll, retry := dataproc.PollInterval, dataproc.RetryInterval
+	dataproc.APIBase, storage.APIBase = s.URL, s.URL
+	dataproc.PollInterval, dataproc.RetryInterval = time.Millisecond, time.Millisecond
+	gs, _, _ := mrfs.Lookup("gs://")
+	mrfs.Register("gs", mrfs.GoogleStorage{Client: s.Client()})
+	t.Cleanup(func() {
+		mrfs.Register("gs", gs)
+		dataproc.APIBase, storage.APIBase = dataprocBase, storageBase
+		dataproc.PollInterval, dataproc.RetryInterval = poll, retry
+	})
+	return s
+}
+
+// FailNext responds to the next n requests with status code (i.e. 503)
+func (s *Server) FailNext(n, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ; n > 0; n-- {
+		s.fail = append(s.fail, code)
+	}
+}
+
+// Job returns a copy of a submitted job
+func (s *Server) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Jobs returns the ID of each submitted job in the order submitted
+func (s *Server) Jobs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.order...)
+}
+
+// PutObject stores an object
+func (s *Server) PutObject(bucket, name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putObject(bucket, name, data)
+}
+
+func (s *Server) putObject(bucket, name string, data []byte) {
+	if s.objects[bucket] == nil {
+		s.objects[bucket] = make(map[string][]byte)
+	}
+	s.objects[bucket][name] = data
+}
+
+// Object returns the contents of an object
+func (s *Server) Object(bucket, name string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[bucket][name]
+	return data, ok
+}
+
+// Objects returns the sorted names of objects in bucket
+func (s *Server) Objects(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedNames(bucket)
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/projects/{project}/regions/{region}/jobs:submit", s.submitJob)
+	mux.HandleFunc("GET /v1/projects/{project}/regions/{region}/jobs/{job}", s.getJob)
+	mux.HandleFunc("POST /v1/projects/{project}/regions/{region}/jobs/{job}", s.cancelJob) // {job}:cancel
+	mux.HandleFunc("POST /upload/storage/v1/b/{bucket}/o", s.insertObject)
+	mux.HandleFunc("GET /storage/v1/b/{bucket}/o", s.listObjects)
+	mux.HandleFunc("GET /storage/v1/b/{bucket}/o/{object...}", s.getObject)
+	mux.HandleFunc("DELETE /storage/v1/b/{bucket}/o/{object...}", s.deleteObject)
+	mux.HandleFunc("GET /ws/v1/cluster/apps/{app}", s.getYarnApp)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		var code int
+		if len(s.fail) > 0 {
+			code, s.fail = s.fail[0], s.fail[1:]
+		}
+		s.mu.Unlock()
+		if code != 0 {
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) // nolint:errcheck
+}
+
+// jobResource is the subset of the Dataproc Job resource read by the dataproc package
+// https://cloud.google.com/dataproc/docs/reference/rest/v1/projects.regions.jobs#Job
+type jobResource struct {
+	Placement struct {
+		ClusterName string `json:"clusterName"`
+	} `json:"placement"`
+	Reference struct {
+		ProjectID string `json:"projectId"`
+		JobID     string `json:"jobId"`
+	} `json:"reference"`
+	HadoopJob struct {
+		Args       []string          `json:"args"`
+		FileURIs   []string          `json:"fileUris,omitempty"`
+		Properties map[string]string `json:"properties,omitempty"`
+	} `json:"hadoopJob"`
+	DriverOutputResourceURI string `json:"driverOutputResourceUri,omitempty"`
+	Status                  struct {
+		State string `json:"state"`
+	} `json:"status"`
+	YarnApplications []yarnApplication `json:"yarnApplications,omitempty"`
+}
+
+type yarnApplication struct {
+	Name        string  `json:"name"`
+	State       string  `json:"state"`
+	Progress    float64 `json:"progress"`
+	TrackingURL string  `json:"trackingUrl,omitempty"`
+}
+
+func (s *Server) resource(j *Job) jobResource {
+	var r jobResource
+	r.Placement.ClusterName = j.Cluster
+	r.Reference.ProjectID = j.Project
+	r.Reference.JobID = j.ID
+	r.HadoopJob.Args = j.Args
+	r.HadoopJob.FileURIs = j.FileURIs
+	r.HadoopJob.Properties = j.Properties
+	r.DriverOutputResourceURI = j.driverOutputURI(s.Bucket)
+	r.Status.State = j.State
+	switch j.State {
+	case "RUNNING":
+		r.YarnApplications = []yarnApplication{{Name: j.Properties["mapred.job.name"], State: "RUNNING", Progress: 0.5, TrackingURL: s.trackingURL(j)}}
+	case "DONE":
+		r.YarnApplications = []yarnApplication{{Name: j.Properties["mapred.job.name"], State: "FINISHED", Progress: 1, TrackingURL: s.trackingURL(j)}}
+	}
+	return r
+}
+
+// trackingURL is the YARN tracking url of j, which is served by getYarnApp.
+// The application is numbered by the order j was submitted. s.mu must be held.
+func (s *Server) trackingURL(j *Job) string {
+	return fmt.Sprintf("%s/proxy/application_0_%04d/", s.URL, slices.Index(s.order, j.ID)+1)
+}
+
+// getYarnApp serves the subset of the YARN ResourceManager application
+// resource read by hdfs.RunningTasks
+func (s *Server) getYarnApp(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int
+	if _, err := fmt.Sscanf(r.PathValue("app"), "application_0_%d", &n); err != nil || n < 1 || n > len(s.order) {
+		http.NotFound(w, r)
+		return
+	}
+	j := s.jobs[s.order[n-1]]
+	var v struct {
+		App struct {
+			State             string `json:"state"`
+			RunningContainers int    `json:"runningContainers"`
+		} `json:"app"`
+	}
+	v.App.State, v.App.RunningContainers = "FINISHED", -1
+	if j.State == "RUNNING" {
+		// the tasks and the MapReduce ApplicationMaster
+		v.App.State, v.App.RunningContainers = "RUNNING", j.RunningTasks+1
+	}
+	writeJSON(w, v)
+}
+
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Job struct {
+			Placement struct {
+				ClusterName      string `json:"clusterName"`
+				ClusterNameSnake string `json:"cluster_name"`
+			} `json:"placement"`
+			Reference struct {
+				JobID string `json:"jobId"`
+			} `json:"reference"`
+			HadoopJob struct {
+				Args       []string          `json:"args"`
+				FileURIs   []string          `json:"fileUris"`
+				Properties map[string]string `json:"properties"`
+			} `json:"hadoopJob"`
+		} `json:"job"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	j := &Job{
+		Project:    r.PathValue("project"),
+		Region:     r.PathValue("region"),
+		Cluster:    req.Job.Placement.ClusterName,
+		ID:         req.Job.Reference.JobID,
+		Args:       req.Job.HadoopJob.Args,
+		FileURIs:   req.Job.HadoopJob.FileURIs,
+		Properties: req.Job.HadoopJob.Properties,
+		State:      "PENDING",
+		States:     append([]string(nil), DefaultStates...),
+	}
+	if j.Cluster == "" {
+		j.Cluster = req.Job.Placement.ClusterNameSnake
+	}
+
+	if s.OnSubmit != nil {
+		s.OnSubmit(j)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j.ID == "" {
+		j.ID = fmt.Sprintf("job-%04d", len(s.order)+1)
+	}
+	if _, ok := s.jobs[j.ID]; ok {
+		http.Error(w, fmt.Sprintf("job %s already exists", j.ID), http.StatusConflict)
+		return
+	}
+	s.jobs[j.ID] = j
+	s.order = append(s.order, j.ID)
+	writeJSON(w, s.resource(j))
+}
+
+// lookupJob returns the job for a request or responds with 404
+func (s *Server) lookupJob(w http.ResponseWriter, r *http.Request, id string) *Job {
+	j, ok := s.jobs[id]
+	if !ok || j.Project != r.PathValue("project") || j.Region != r.PathValue("region") {
+		http.Error(w, fmt.Sprintf("job %s not found", id), http.StatusNotFound)
+		return nil
+	}
+	return j
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.lookupJob(w, r, r.PathValue("job"))
+	if j == nil {
+		return
+	}
+	j.Gets++
+	if len(j.States) > 0 {
+		j.State, j.States = j.States[0], j.States[1:]
+		if isTerminal(j.State) && j.DriverOutput != "" {
+			s.putObject(s.Bucket, strings.TrimPrefix(j.driverOutputURI(s.Bucket), "gs://"+s.Bucket+"/")+".000000000", []byte(j.DriverOutput))
+		}
+	}
+	writeJSON(w, s.resource(j))
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := strings.CutSuffix(r.PathValue("job"), ":cancel")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.lookupJob(w, r, id)
+	if j == nil {
+		return
+	}
+	if isTerminal(j.State) {
+		http.Error(w, fmt.Sprintf("job %s is in state %s", id, j.State), http.StatusBadRequest)
+		return
+	}
+	j.State, j.States = "CANCEL_PENDING", []string{"CANCELLED"}
+	writeJSON(w, s.resource(j))
+}
+
+func isTerminal(state string) bool {
+	switch state {
+	case "DONE", "ERROR", "CANCELLED", "ATTEMPT_FAILURE":
+		return true
+	}
+	return false
+}
+
+// object is the subset of the Storage Object resource read by the storage package
+type object struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Bucket string `json:"bucket"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size,string"`
+}
+
+func newObject(bucket, name string, data []byte) object {
+	return object{Kind: "storage#object", ID: bucket + "/" + name, Bucket: bucket, Name: name, Size: int64(len(data))}
+}
+
+func (s *Server) insertObject(w http.ResponseWriter, r *http.Request) {
+	bucket, name := r.PathValue("bucket"), r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "missing name", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.PutObject(bucket, name, data)
+	writeJSON(w, newObject(bucket, name, data))
+}
+
+// listObjects lists objects matching prefix, grouping names that contain
+// delimiter after prefix into prefixes. pageToken is the last object or prefix
+// of the previous page.
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	prefix, delimiter, token := r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"), r.URL.Query().Get("pageToken")
+	var resp struct {
+		Kind          string   `json:"kind"`
+		NextPageToken string   `json:"nextPageToken,omitempty"`
+		Prefixes      []string `json:"prefixes,omitempty"`
+		Items         []object `json:"items"`
+	}
+	resp.Kind = "storage#objects"
+	s.mu.Lock()
+	var last string
+	for _, name := range s.sortedNames(bucket) {
+		if !strings.HasPrefix(name, prefix) || (token != "" && name <= token) {
+			continue
+		}
+		entry, isPrefix := name, false
+		if i := strings.Index(name[len(prefix):], delimiter); delimiter != "" && i != -1 {
+			entry, isPrefix = name[:len(prefix)+i+len(delimiter)], true
+			if entry == last || (token != "" && entry <= token) {
+				continue
+			}
+		}
+		if len(resp.Items)+len(resp.Prefixes) == s.PageSize {
+			resp.NextPageToken = last
+			break
+		}
+		if !isPrefix {
+			resp.Items = append(resp.Items, newObject(bucket, name, s.objects[bucket][name]))
+		} else {
+			resp.Prefixes = append(resp.Prefixes, entry)
+		}
+		last = entry
+	}
+	s.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+func (s *Server) sortedNames(bucket string) []string {
+	var names []string
+	for name := range s.objects[bucket] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request) {
+	bucket, name := r.PathValue("bucket"), r.PathValue("object")
+	data, ok := s.Object(bucket, name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("gs://%s/%s not found", bucket, name), http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("alt") == "media" {
+		w.Write(data) // nolint:errcheck
+		return
+	}
+	writeJSON(w, newObject(bucket, name, data))
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request) {
+	bucket, name := r.PathValue("bucket"), r.PathValue("object")
+	s.mu.Lock()
+	_, ok := s.objects[bucket][name]
+	delete(s.objects[bucket], name)
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("gs://%s/%s not found", bucket, name), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package hdfs
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Counters are the job counters reported when a job completes, by group and name
+type Counters map[string]map[string]int64
+
+// Get returns the value of a counter, or 0 if it was not reported
+func (c Counters) Get(group, name string) int64 {
+	return c[group][name]
+}
+
+// FailedTasks returns the number of failed map and reduce task attempts
+func (c Counters) FailedTasks() int {
+	return int(c.Get("Job Counters", "Failed map tasks") + c.Get("Job Counters", "Failed reduce tasks"))
+}
+
+func (c Counters) add(group, name string, value int64) {
+	if c[group] == nil {
+		c[group] = make(map[string]int64)
+	}
+	c[group][name] += value
+}
+
+var countersRe = regexp.MustCompile(`Counters: \d+$`)
+
+// countersParser accumulates the counter block logged by hadoop when a job
+// completes:
+//
+//	INFO mapreduce.Job: Counters: 49
+//		File System Counters
+//			FILE: Number of bytes read=1234
+type countersParser struct {
+	counters Counters
+	inBlock  bool
+	group    string
+}
+
+// parseLine returns true if line was part of a counters block
+func (p *countersParser) parseLine(line []byte) bool {
+	line = bytes.TrimRight(line, "\r")
+	if countersRe.Match(line) {
+		p.inBlock = true
+		if p.counters == nil {
+			p.counters = make(Counters)
+		}
+		return true
+	}
+	if !p.inBlock {
+		return false
+	}
+	switch {
+	case bytes.HasPrefix(line, []byte("\t\t")):
+		i := bytes.LastIndexByte(line, '=')
+		if i == -1 {
+			return true
+		}
+		value, err := strconv.ParseInt(string(bytes.TrimSpace(line[i+1:])), 10, 64)
+		if err == nil {
+			p.counters.add(p.group, string(bytes.TrimSpace(line[:i])), value)
+		}
+	case bytes.HasPrefix(line, []byte("\t")):
+		p.group = string(bytes.TrimSpace(line))
+	default:
+		p.inBlock = false
+		return false
+	}
+	return true
+}
+
+// ParseCounters reads the counters from the output of `hadoop jar`
+func ParseCounters(r io.Reader) Counters {
+	var p countersParser
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			p.parseLine(bytes.TrimRight(line, "\n"))
+		}
+		if err != nil {
+			break
+		}
+	}
+	return p.counters
+}
@@ -0,0 +1,39 @@
+package hdfs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseCounters(t *testing.T) {
+	output := `23/09/06 15:23:51 INFO mapreduce.Job: Job job_1693000000000_0042 completed successfully
+23/09/06 15:23:51 INFO mapreduce.Job: Counters: 4
+	File System Counters
+		FILE: Number of bytes read=1234
+	Job Counters 
+		Launched map tasks=2
+	gomrjob
+		mapper[0] userTime (ms)=1510
+		mapper[0] systemTime (ms)=20
+23/09/06 15:23:51 INFO streaming.StreamJob: Output directory: /tmp/output
+`
+	c := ParseCounters(bytes.NewBufferString(output))
+	type testCase struct {
+		group, name string
+		expect      int64
+	}
+	tests := []testCase{
+		{"File System Counters", "FILE: Number of bytes read", 1234},
+		{"Job Counters", "Launched map tasks", 2},
+		{"gomrjob", "mapper[0] userTime (ms)", 1510},
+		{"gomrjob", "mapper[0] systemTime (ms)", 20},
+	}
+	for i, tc := range tests {
+		if got := c.Get(tc.group, tc.name); got != tc.expect {
+			t.Errorf("test[%d] got %d expected %d for %s %s", i, got, tc.expect, tc.group, tc.name)
+		}
+	}
+	if len(c) != 3 {
+		t.Errorf("got %d groups expected 3", len(c))
+	}
+}
@@ -0,0 +1,123 @@
+package hdfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Errors returned (wrapped in a *FsError) by filesystem commands. They can be
+// checked with errors.Is, and ErrNotExist, ErrPermission and ErrAlreadyExists
+// also match their io/fs equivalents.
+var (
+	ErrNotExist      = fs.ErrNotExist
+	ErrPermission    = fs.ErrPermission
+	ErrAlreadyExists = fs.ErrExist
+	ErrConnection    = errors.New("hdfs: connection failed")
+)
+
+// FsError records a failed `hadoop fs` command and the error output it produced
+type FsError struct {
+	Command string
+	Args    []string
+	Stderr  string
+	Err     error // one of the Err* values when the failure was recognized
+	Exit    error // the underlying *exec.ExitError (or exec failure)
+}
+
+func (e *FsError) Error() string {
+	msg := errorLine(e.Command, e.Stderr)
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if msg == "" && e.Exit != nil {
+		msg = e.Exit.Error()
+	}
+	return fmt.Sprintf("hadoop fs %s %s: %s", e.Command, strings.Join(e.Args, " "), msg)
+}
+
+func (e *FsError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if e.Exit != nil {
+		errs = append(errs, e.Exit)
+	}
+	return errs
+}
+
+// ExitCode returns the exit status of the hadoop command, or -1 if it didn't run
+func (e *FsError) ExitCode() int {
+	var exitErr *exec.ExitError
+	if errors.As(e.Exit, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+func newFsError(command string, args []string, stderr string, err error) *FsError {
+	return &FsError{
+		Command: command,
+		Args:    args,
+		Stderr:  strings.TrimSpace(stderr),
+		Err:     classifyStderr(withoutLogLines(stderr)),
+		Exit:    err,
+	}
+}
+
+// classifyStderr maps the error output of `hadoop fs` to one of the Err* values
+func classifyStderr(stderr string) error {
+	switch {
+	case stderr == "":
+		return nil
+	case strings.Contains(stderr, "No such file or directory"),
+		strings.Contains(stderr, "FileNotFoundException"):
+		return ErrNotExist
+	case strings.Contains(stderr, "Permission denied"),
+		strings.Contains(stderr, "AccessControlException"):
+		return ErrPermission
+	case strings.Contains(stderr, "File exists"),
+		strings.Contains(stderr, "already exists"),
+		strings.Contains(stderr, "FileAlreadyExistsException"):
+		return ErrAlreadyExists
+	case strings.Contains(stderr, "Connection refused"),
+		strings.Contains(stderr, "ConnectException"),
+		strings.Contains(stderr, "failed on connection exception"),
+		strings.Contains(stderr, "UnknownHostException"),
+		strings.Contains(stderr, "NoRouteToHostException"),
+		strings.Contains(stderr, "SocketTimeoutException"):
+		return ErrConnection
+	}
+	return nil
+}
+
+// logLineRe matches log4j output (i.e. "24/01/02 10:00:00 WARN util.NativeCodeLoader: ...")
+// which hadoop writes to stderr on many clusters regardless of the result
+var logLineRe = regexp.MustCompile(`^(\d\S* \d\S* )?(TRACE|DEBUG|INFO|WARN) |^log4j:(WARN|INFO) `)
+
+// withoutLogLines removes log4j lines from the error output of a command
+func withoutLogLines(stderr string) string {
+	var lines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if !logLineRe.MatchString(line) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// errorLine returns the "<command>: ..." line of stderr or the last line that isn't logging
+func errorLine(command, stderr string) string {
+	lines := strings.Split(withoutLogLines(stderr), "\n")
+	prefix := strings.TrimPrefix(command, "-") + ": "
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	return lines[len(lines)-1]
+}
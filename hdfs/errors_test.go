@@ -0,0 +1,68 @@
+package hdfs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestClassifyStderr(t *testing.T) {
+	type testCase struct {
+		stderr string
+		expect error
+	}
+	tests := []testCase{
+		{"", nil},
+		{"test: `/tmp/missing': No such file or directory\n", ErrNotExist},
+		{"put: Permission denied: user=nobody, access=WRITE, inode=\"/user\"\n", ErrPermission},
+		{"mkdir: `/tmp/a': File exists\n", ErrAlreadyExists},
+		{"ls: Call From host/10.0.0.1 to namenode:8020 failed on connection exception: java.net.ConnectException: Connection refused\n", ErrConnection},
+		{"something unexpected\n", nil},
+	}
+	for i, tc := range tests {
+		got := classifyStderr(tc.stderr)
+		if got != tc.expect {
+			t.Errorf("test[%d] got %v expected %v for %q", i, got, tc.expect, tc.stderr)
+		}
+	}
+}
+
+func TestFsErrorIs(t *testing.T) {
+	err := error(newFsError("-ls", []string{"/missing"}, "ls: `/missing': No such file or directory\n", errors.New("exit status 1")))
+	if !errors.Is(err, ErrNotExist) {
+		t.Errorf("expected errors.Is(err, ErrNotExist)")
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected errors.Is(err, fs.ErrNotExist)")
+	}
+	if errors.Is(err, ErrConnection) {
+		t.Errorf("unexpected errors.Is(err, ErrConnection)")
+	}
+	if got, expect := err.Error(), "hadoop fs -ls /missing: ls: `/missing': No such file or directory"; got != expect {
+		t.Errorf("got %q expected %q", got, expect)
+	}
+}
+
+func TestFsErrorLogLines(t *testing.T) {
+	warn := "24/01/02 10:00:00 WARN util.NativeCodeLoader: Unable to load native-hadoop library for your platform... using builtin-java classes where applicable\n"
+	type testCase struct {
+		stderr string
+		err    error
+		msg    string
+	}
+	tests := []testCase{
+		{warn, nil, "exit status 1"},
+		{warn + "test: `/missing': No such file or directory\n", ErrNotExist, "test: `/missing': No such file or directory"},
+		{"log4j:WARN No appenders could be found for logger\n" + warn + "java.io.IOException: stream closed\n", nil, "java.io.IOException: stream closed"},
+		{warn + "test: `/x': Permission denied\n24/01/02 10:00:01 INFO fs.TrashPolicy: done\n", ErrPermission, "test: `/x': Permission denied"},
+	}
+	for i, tc := range tests {
+		err := newFsError("-test", []string{"-e", "/x"}, tc.stderr, errors.New("exit status 1"))
+		if err.Err != tc.err {
+			t.Errorf("test[%d] got %v expected %v", i, err.Err, tc.err)
+		}
+		if got, expect := err.Error(), "hadoop fs -test -e /x: "+tc.msg; got != expect {
+			t.Errorf("test[%d] got %q expected %q", i, got, expect)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -60,14 +61,36 @@ func StreamingJar() (string, error) {
 	return streamingJarPath, nil
 }
 
+// AbsolutePath qualifies path with proto (i.e. "hdfs:///" or "gs://bucket/")
+// unless it already includes a supported scheme
+func AbsolutePath(path, proto string) string {
+	return absolutePath(path, proto)
+}
+
 // http://hadoop.apache.org/docs/r0.20.2/hdfs_shell.html
 
+// FsCmd runs `hadoop fs <command> <args>`. Output from the command is copied to
+// stderr; on failure the error output is returned as a *FsError
 func FsCmd(command string, args ...string) error {
+	return fsCmd(os.Stderr, command, args...)
+}
+
+func fsCmd(stdout io.Writer, command string, args ...string) error {
+	return runFsCmd(stdout, true, command, args...)
+}
+
+func runFsCmd(stdout io.Writer, logCmd bool, command string, args ...string) error {
 	cmd := exec.Command(hadoopBinPath("hadoop"), append([]string{"fs", command}, args...)...)
-	log.Print(cmd.Args)
-	cmd.Stdout = os.Stderr
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	if logCmd {
+		log.Print(cmd.Args)
+	}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return newFsError(command, args, stderr.String(), err)
+	}
+	return nil
 }
 
 func Mkdir(remote string) error {
@@ -83,6 +106,85 @@ func Test(flag string, remote string) error {
 	return FsCmd("-test", flag, remote)
 }
 
+// testFlag runs `-test` with flag, distinguishing a false result (exit status 1
+// without a recognized error) from a failure to run the check
+func testFlag(flag string, remote string) (bool, error) {
+	err := Test(flag, remote)
+	if err == nil {
+		return true, nil
+	}
+	var fsErr *FsError
+	if !errors.As(err, &fsErr) {
+		return false, err
+	}
+	if errors.Is(err, ErrNotExist) || (fsErr.ExitCode() == 1 && fsErr.Err == nil) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Exists reports if remote exists. An error is returned only when the check
+// could not be completed (i.e. the NameNode is unreachable)
+func Exists(remote string) (bool, error) {
+	return testFlag("-e", remote)
+}
+
+// IsDir reports if remote exists and is a directory
+func IsDir(remote string) (bool, error) {
+	return testFlag("-d", remote)
+}
+
+// Stat returns the listing for remote. Errors wrap ErrNotExist when
+// the path does not exist.
+func Stat(remote string) (*HdfsFile, error) {
+	files, err := lsDir(remote, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, newFsError("-ls", []string{"-d", remote}, "No such file or directory", nil)
+	}
+	return files[0], nil
+}
+
+// Glob returns the listing of all paths matching pattern. Directories that
+// match are returned as an entry rather than expanded to their contents.
+func Glob(pattern string) ([]*HdfsFile, error) {
+	return glob(pattern, true)
+}
+
+// GlobQuiet is Glob without logging the command, for polling
+func GlobQuiet(pattern string) ([]*HdfsFile, error) {
+	return glob(pattern, false)
+}
+
+func glob(pattern string, logCmd bool) ([]*HdfsFile, error) {
+	files, err := lsDir(pattern, logCmd)
+	if errors.Is(err, ErrNotExist) {
+		return nil, nil
+	}
+	return files, err
+}
+
+func lsDir(remote string, logCmd bool) ([]*HdfsFile, error) {
+	var stdout bytes.Buffer
+	if err := runFsCmd(&stdout, logCmd, "-ls", "-d", remote); err != nil {
+		return nil, err
+	}
+	var files []*HdfsFile
+	for _, line := range bytes.Split(stdout.Bytes(), []byte("\n")) {
+		if len(line) == 0 || bytes.HasPrefix(line, []byte("Found ")) {
+			continue
+		}
+		file, err := newHdfsFile(splitLsOutput(line))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
 func Put(args ...string) error {
 	return FsCmd("-put", args...)
 }
@@ -112,6 +214,110 @@ func Move(args ...string) error {
 	return FsCmd("-mv", args...)
 }
 
+// Create returns a writer that streams to a new file at remote. The upload is
+// complete when Close returns.
+func Create(remote string) (io.WriteCloser, error) {
+	return create(remote, true)
+}
+
+// CreateQuiet is Create without logging the command, for writing log output
+// without generating more of it
+func CreateQuiet(remote string) (io.WriteCloser, error) {
+	return create(remote, false)
+}
+
+func create(remote string, logCmd bool) (io.WriteCloser, error) {
+	args := []string{"-", remote}
+	cmd := exec.Command(hadoopBinPath("hadoop"), append([]string{"fs", "-put"}, args...)...)
+	if logCmd {
+		log.Print(cmd.Args)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	w := &cmdWriter{WriteCloser: stdin, cmd: cmd, args: args}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = &w.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+type cmdWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	args   []string
+	stderr bytes.Buffer
+}
+
+func (w *cmdWriter) Close() error {
+	w.WriteCloser.Close()
+	if err := w.cmd.Wait(); err != nil {
+		return newFsError("-put", w.args, w.stderr.String(), err)
+	}
+	return nil
+}
+
+// Open returns a reader for the contents of remote (which may be a glob).
+// A failure to read is returned as an error from Read once output is exhausted.
+func Open(remote string) (io.ReadCloser, error) {
+	return open(Cat(remote), remote)
+}
+
+// OpenQuiet is Open without logging the command, for polling
+func OpenQuiet(remote string) (io.ReadCloser, error) {
+	return open(exec.Command(hadoopBinPath("hadoop"), "fs", "-cat", remote), remote)
+}
+
+func open(cmd *exec.Cmd, remote string) (io.ReadCloser, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	r := &cmdReader{r: stdout, cmd: cmd, args: []string{remote}}
+	cmd.Stderr = &r.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type cmdReader struct {
+	r      io.ReadCloser
+	cmd    *exec.Cmd
+	args   []string
+	stderr bytes.Buffer
+	done   bool
+	err    error
+}
+
+func (r *cmdReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF && !r.done {
+		r.done = true
+		if waitErr := r.cmd.Wait(); waitErr != nil {
+			r.err = newFsError("-cat", r.args, r.stderr.String(), waitErr)
+		}
+	}
+	if err == io.EOF && r.err != nil {
+		return n, r.err
+	}
+	return n, err
+}
+
+// Close stops the underlying command if the output was not read to completion
+func (r *cmdReader) Close() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	r.cmd.Process.Kill() // nolint:errcheck
+	r.cmd.Wait()         // nolint:errcheck
+	return nil
+}
+
 func Cat(args ...string) *exec.Cmd {
 	cmd := exec.Command(hadoopBinPath("hadoop"), append([]string{"fs", "-cat"}, args...)...)
 	log.Print(cmd.Args)
@@ -164,7 +370,7 @@ func newHdfsFile(chunks []string) (*HdfsFile, error) {
 	if len(chunks) != 8 {
 		return nil, errors.New("invalid file parts")
 	}
-	file := &HdfsFile{}
+	file := &HdfsFile{Permissions: chunks[0]}
 	// log.Printf("split: %#v", chunks)
 	file.ReplicaCount, err = strconv.ParseInt(chunks[1], 10, 64)
 	if err != nil {
@@ -205,3 +411,8 @@ type HdfsFile struct {
 	Modified     time.Time
 	Path         string
 }
+
+// IsDir reports whether the listing is for a directory
+func (f *HdfsFile) IsDir() bool {
+	return strings.HasPrefix(f.Permissions, "d")
+}
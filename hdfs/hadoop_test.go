@@ -2,8 +2,12 @@ package hdfs
 
 import (
 	"bytes"
-	"github.com/stretchr/testify/assert"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestLs(t *testing.T) {
@@ -17,6 +21,8 @@ Found 1 items
 	out := make(chan *HdfsFile)
 	go parseLsOutput(bytes.NewBufferString(shuntData), out)
 	f1 := <-out
+	assert.Equal(t, f1.Permissions, "-rw-r--r--")
+	assert.Equal(t, f1.IsDir(), false)
 	assert.Equal(t, f1.ReplicaCount, int64(3))
 	assert.Equal(t, f1.User, "jehiah")
 	assert.Equal(t, f1.Size, int64(176572))
@@ -25,3 +31,35 @@ Found 1 items
 	assert.Equal(t, f2.Path, "hdfs:///user/jehiah/tmp/mrjob/a.jehiah.20130906.141932.492122/step-output/1/part-00009")
 
 }
+
+// fakeHadoop installs a `hadoop` script that writes stderr and exits with code
+func fakeHadoop(t *testing.T, stderr string, code int) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "bin"), 0755)
+	if err := os.WriteFile(filepath.Join(dir, "stderr"), []byte(stderr), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\ncat %s/stderr >&2\nexit %d\n", dir, code)
+	if err := os.WriteFile(filepath.Join(dir, "bin", "hadoop"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HADOOP_HOME", dir)
+}
+
+func TestExistsWithLogging(t *testing.T) {
+	warn := "24/01/02 10:00:00 WARN util.NativeCodeLoader: Unable to load native-hadoop library for your platform\n"
+
+	fakeHadoop(t, warn, 1)
+	ok, err := Exists("/missing")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ok, false)
+
+	fakeHadoop(t, warn, 0)
+	ok, err = IsDir("/dir")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ok, true)
+
+	fakeHadoop(t, warn+"test: Call From host to namenode:8020 failed on connection exception: java.net.ConnectException: Connection refused\n", 1)
+	_, err = Exists("/x")
+	assert.ErrorIs(t, err, ErrConnection)
+}
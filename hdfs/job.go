@@ -3,6 +3,7 @@ package hdfs
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -22,6 +23,9 @@ type Job struct {
 	Files        []string          // -file
 
 	DefaultProto string // protocol for relative files
+
+	OnProgress func(Progress) // optional; called as the job progresses
+	OnCounters func(Counters) // optional; called with the job counters when the job completes, successfully or not
 }
 
 func absolutePath(path, proto string) string {
@@ -103,5 +107,14 @@ func SubmitJob(j Job) error {
 	log.Print(cmd.Args)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	var pw *progressWriter
+	if j.OnProgress != nil || j.OnCounters != nil {
+		pw = newProgressWriter(j.OnProgress)
+		cmd.Stderr = io.MultiWriter(os.Stderr, pw)
+	}
+	err = cmd.Run()
+	if pw != nil && pw.counters.counters != nil && j.OnCounters != nil {
+		j.OnCounters(pw.counters.counters)
+	}
+	return err
 }
@@ -0,0 +1,127 @@
+package hdfs
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Progress is a snapshot of a running job
+type Progress struct {
+	JobID        string
+	State        string  // i.e. RUNNING, SUCCEEDED, FAILED
+	Map          float64 // percent complete
+	Reduce       float64 // percent complete
+	Overall      float64 // percent complete when Map and Reduce are not known separately (Dataproc)
+	RunningTasks int     // running task attempts, when the YARN ResourceManager is reachable (see RunningTasks)
+	FailedTasks  int     // failed task attempts so far (for Dataproc, known only when the job finishes)
+	Elapsed      time.Duration
+}
+
+// Percent returns the overall completion of the job
+func (p Progress) Percent() float64 {
+	if p.Overall != 0 {
+		return p.Overall
+	}
+	return (p.Map + p.Reduce) / 2
+}
+
+// ETA estimates the time remaining from the rate of progress so far (0 if unknown)
+func (p Progress) ETA() time.Duration {
+	pct := p.Percent()
+	if pct <= 0 || pct >= 100 {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * (100 - pct) / pct).Round(time.Second)
+}
+
+var (
+	runningJobRe = regexp.MustCompile(`Running job: (job_\S+)`)
+	trackingRe   = regexp.MustCompile(`The url to track the job: (\S+)`)
+	percentRe    = regexp.MustCompile(`map\s+(\d+)%\s+reduce\s+(\d+)%`)
+	failedTaskRe = regexp.MustCompile(`Task Id : (attempt_\S+), Status : FAILED`)
+	completedRe  = regexp.MustCompile(`Job (job_\S+) completed successfully`)
+	jobFailedRe  = regexp.MustCompile(`Job (job_\S+) failed with state (\w+)`)
+)
+
+// progressWriter parses the log output of `hadoop jar hadoop-streaming.jar`,
+// calling f (if set) on each change in progress and collecting job counters
+type progressWriter struct {
+	f        func(Progress)
+	start    time.Time
+	p        Progress
+	buf      []byte
+	counters countersParser
+
+	trackingURL string
+	yarnFailed  bool // the ResourceManager could not be queried for running tasks
+}
+
+func newProgressWriter(f func(Progress)) *progressWriter {
+	return &progressWriter{f: f, start: time.Now()}
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		w.parseLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+func (w *progressWriter) parseLine(line []byte) {
+	if w.counters.parseLine(line) {
+		return
+	}
+	if w.f == nil {
+		return
+	}
+	changed := true
+	if m := runningJobRe.FindSubmatch(line); m != nil {
+		w.p.JobID = string(m[1])
+		w.p.State = "RUNNING"
+	} else if m := trackingRe.FindSubmatch(line); m != nil {
+		w.trackingURL = string(m[1])
+		changed = false
+	} else if m := percentRe.FindSubmatch(line); m != nil {
+		w.p.Map, _ = strconv.ParseFloat(string(m[1]), 64)
+		w.p.Reduce, _ = strconv.ParseFloat(string(m[2]), 64)
+		w.updateRunningTasks()
+	} else if failedTaskRe.Match(line) {
+		w.p.FailedTasks++
+	} else if completedRe.Match(line) {
+		w.p.State = "SUCCEEDED"
+		w.p.RunningTasks = 0
+	} else if m := jobFailedRe.FindSubmatch(line); m != nil {
+		w.p.State = string(m[2])
+		w.p.RunningTasks = 0
+	} else {
+		changed = false
+	}
+	if changed {
+		w.p.Elapsed = time.Since(w.start)
+		w.f(w.p)
+	}
+}
+
+// updateRunningTasks queries the ResourceManager for running tasks; after the
+// first failure it is not queried again
+func (w *progressWriter) updateRunningTasks() {
+	if w.trackingURL == "" || w.yarnFailed {
+		return
+	}
+	n, err := RunningTasks(w.trackingURL)
+	if err != nil {
+		log.Printf("failed getting running tasks %s", err)
+		w.yarnFailed = true
+		return
+	}
+	w.p.RunningTasks = n
+}
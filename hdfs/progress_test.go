@@ -0,0 +1,62 @@
+package hdfs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProgressWriter(t *testing.T) {
+	// a fake ResourceManager with 2 running tasks
+	rm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws/v1/cluster/apps/application_1693000000000_0042" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"app":{"state":"RUNNING","runningContainers":3}}`)
+	}))
+	defer rm.Close()
+
+	output := `packageJobJar: [] [/usr/lib/hadoop-mapreduce/hadoop-streaming.jar] /tmp/streamjob.jar tmpDir=null
+23/09/06 15:23:01 INFO mapreduce.Job: The url to track the job: ` + rm.URL + `/proxy/application_1693000000000_0042/
+23/09/06 15:23:01 INFO mapreduce.Job: Running job: job_1693000000000_0042
+23/09/06 15:23:09 INFO mapreduce.Job:  map 0% reduce 0%
+23/09/06 15:23:20 INFO mapreduce.Job:  map 45% reduce 0%
+23/09/06 15:23:31 INFO mapreduce.Job: Task Id : attempt_1693000000000_0042_m_000003_0, Status : FAILED
+23/09/06 15:23:42 INFO mapreduce.Job:  map 100% reduce 30%
+23/09/06 15:23:50 INFO mapreduce.Job:  map 100% reduce 100%
+23/09/06 15:23:51 INFO mapreduce.Job: Job job_1693000000000_0042 completed successfully
+`
+	var got []string
+	w := newProgressWriter(func(p Progress) {
+		got = append(got, fmt.Sprintf("%s %s %v/%v running:%d failed:%d", p.JobID, p.State, p.Map, p.Reduce, p.RunningTasks, p.FailedTasks))
+	})
+	// write in uneven chunks to exercise line buffering
+	for i := 0; i < len(output); i += 7 {
+		w.Write([]byte(output[i:min(i+7, len(output))])) // nolint:errcheck
+	}
+	expected := []string{
+		"job_1693000000000_0042 RUNNING 0/0 running:0 failed:0",
+		"job_1693000000000_0042 RUNNING 0/0 running:2 failed:0",
+		"job_1693000000000_0042 RUNNING 45/0 running:2 failed:0",
+		"job_1693000000000_0042 RUNNING 45/0 running:2 failed:1",
+		"job_1693000000000_0042 RUNNING 100/30 running:2 failed:1",
+		"job_1693000000000_0042 RUNNING 100/100 running:2 failed:1",
+		"job_1693000000000_0042 SUCCEEDED 100/100 running:0 failed:1",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("got %q expected %q", got, expected)
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	p := Progress{Map: 100, Reduce: 0, Elapsed: time.Minute}
+	if got := p.ETA(); got != time.Minute {
+		t.Errorf("got %s expected 1m", got)
+	}
+	if got := (Progress{}).ETA(); got != 0 {
+		t.Errorf("got %s expected 0", got)
+	}
+}
@@ -0,0 +1,45 @@
+package hdfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// YarnClient is used to query the YARN ResourceManager for the running tasks
+// of a job
+var YarnClient = &http.Client{Timeout: 5 * time.Second}
+
+// trackingURLRe matches the ResourceManager and application of a tracking url
+// (i.e. "http://rm:8088/proxy/application_1693000000000_0042/")
+var trackingURLRe = regexp.MustCompile(`^(https?://[^/]+)/proxy/(application_\d+_\d+)`)
+
+// RunningTasks returns the number of running task attempts of the YARN
+// application at trackingURL from the ResourceManager REST API. The MapReduce
+// ApplicationMaster is not counted.
+func RunningTasks(trackingURL string) (int, error) {
+	m := trackingURLRe.FindStringSubmatch(trackingURL)
+	if m == nil {
+		return 0, fmt.Errorf("unrecognized tracking url %q", trackingURL)
+	}
+	resp, err := YarnClient.Get(fmt.Sprintf("%s/ws/v1/cluster/apps/%s", m[1], m[2]))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("got status code %d from %s", resp.StatusCode, resp.Request.URL)
+	}
+	var v struct {
+		App struct {
+			RunningContainers int `json:"runningContainers"`
+		} `json:"app"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return 0, err
+	}
+	// runningContainers is -1 once the application has finished
+	return max(v.App.RunningContainers-1, 0), nil
+}
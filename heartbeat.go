@@ -0,0 +1,94 @@
+package gomrjob
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	currentKey       atomic.Pointer[string]
+	heartbeatRunning atomic.Bool
+)
+
+// SetCurrentKey records the key a task is processing for heartbeat status
+// messages. The grouping mrproto input protocols set it as each key starts.
+func SetCurrentKey(key []byte) {
+	if !heartbeatRunning.Load() {
+		return
+	}
+	if len(key) > 100 {
+		key = key[:100]
+	}
+	k := string(key)
+	currentKey.Store(&k)
+}
+
+// recordsProcessed is the total of RecordsIn for all protocols
+func recordsProcessed() int64 {
+	var n int64
+	records.Range(func(k, v any) bool {
+		if k.(string)[:3] == "in " {
+			n += v.(*atomic.Int64).Load()
+		}
+		return true
+	})
+	return n
+}
+
+// heartbeat periodically reports task status so that tasks that are busy
+// but write no output are not killed by mapreduce.task.timeout
+type heartbeat struct {
+	prefix string
+	start  time.Time
+	last   int64
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func startHeartbeat(prefix string, interval time.Duration) *heartbeat {
+	h := &heartbeat{
+		prefix: prefix,
+		start:  time.Now(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	heartbeatRunning.Store(true)
+	go func() {
+		defer close(h.done)
+		defer heartbeatRunning.Store(false)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.beat()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+	return h
+}
+
+// beat writes a status line and counts heartbeats where no records were read
+func (h *heartbeat) beat() {
+	n := recordsProcessed()
+	status := fmt.Sprintf("%s %d records in %s", h.prefix, n, time.Since(h.start).Round(time.Second))
+	if k := currentKey.Load(); k != nil {
+		status += fmt.Sprintf(" key %q", *k)
+	}
+	if n == h.last {
+		Counter("gomrjob", "heartbeats without progress", 1)
+		status += " (no progress)"
+	}
+	h.last = n
+	Status(status)
+}
+
+func (h *heartbeat) Stop() {
+	h.once.Do(func() { close(h.stop) })
+	<-h.done
+}
@@ -0,0 +1,39 @@
+package gomrjob
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var out bytes.Buffer
+	defer SetReporterOutput(SetReporterOutput(&out))
+
+	h := startHeartbeat("reducer[0]", time.Hour)
+	defer h.Stop()
+	records := RecordsIn("heartbeat test")
+	records.Add(10)
+	SetCurrentKey([]byte("a"))
+	before := recordsProcessed()
+
+	h.beat()
+	assert.Contains(t, out.String(), "reporter:status:reducer[0] ")
+	assert.Contains(t, out.String(), ` key "a"`)
+	assert.NotContains(t, out.String(), "no progress")
+	out.Reset()
+
+	h.beat()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, lines[0], "reporter:counter:gomrjob,heartbeats without progress,1")
+	assert.Contains(t, lines[1], "(no progress)")
+	out.Reset()
+
+	records.Add(1)
+	h.beat()
+	assert.Equal(t, recordsProcessed(), before+1)
+	assert.NotContains(t, out.String(), "no progress")
+}
@@ -0,0 +1,39 @@
+package gomrjob
+
+import (
+	"time"
+
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+// Hooks are optional functions called by Run as it submits a job. An error
+// returned from BeforeUpload or BeforeStep stops Run with that error.
+type Hooks struct {
+	BeforeUpload func(r *Runner) error    // before the running binary and Files are uploaded
+	BeforeStep   func(e *StepEvent) error // before each step is submitted; e.Job may be modified
+	AfterStep    func(e StepEvent)        // after each step completes successfully
+	StepFailed   func(e StepEvent)        // after a step fails
+	Completed    func(r RunResult)        // when Run finishes, successfully or not
+}
+
+// StepEvent describes the submission of one step
+type StepEvent struct {
+	Step     int // index into Runner.Steps
+	Job      hdfs.Job
+	Start    time.Time
+	Duration time.Duration // set once the step has finished
+	Progress hdfs.Progress // the last progress reported, including the JobID
+	Counters hdfs.Counters // job counters, when reported by the cluster
+
+	// Resources used by the tasks of the step by stage, when Counters are reported
+	Resources map[string]*StageResources
+	Err       error
+}
+
+// RunResult describes a completed call to Run
+type RunResult struct {
+	Start    time.Time
+	Duration time.Duration
+	Steps    []StepEvent // the steps submitted
+	Err      error
+}
@@ -0,0 +1,39 @@
+package gomrjob
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubmitJobHooks(t *testing.T) {
+	t.Setenv("HADOOP_HOME", "")
+	t.Setenv("HADOOP_STREAMING_JAR", "")
+
+	var failed, after []StepEvent
+	r := NewRunner()
+	r.Name = "hooks"
+	r.Steps = []Step{fixedReducers{}}
+	r.Progress = ProgressFunc(func(StepProgress) {})
+	r.Hooks.BeforeStep = func(e *StepEvent) error {
+		e.Job.Name = "renamed"
+		return nil
+	}
+	r.Hooks.StepFailed = func(e StepEvent) { failed = append(failed, e) }
+	r.Hooks.AfterStep = func(e StepEvent) { after = append(after, e) }
+
+	// without hadoop configured submission fails
+	e := r.submitJob(nil, 0, r.Steps[0])
+	assert.NotEqual(t, e.Err, nil)
+	assert.Equal(t, e.Job.Name, "renamed")
+	assert.Equal(t, e.Job.ReducerTasks, 7)
+	assert.Equal(t, len(failed), 1)
+	assert.Equal(t, len(after), 0)
+
+	abort := errors.New("abort")
+	r.Hooks.BeforeStep = func(e *StepEvent) error { return abort }
+	e = r.submitJob(nil, 0, r.Steps[0])
+	assert.Equal(t, e.Err, abort)
+	assert.Equal(t, len(failed), 1)
+}
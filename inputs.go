@@ -0,0 +1,132 @@
+package gomrjob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/jehiah/gomrjob/mrfs"
+)
+
+// InputCheck controls how Run validates InputFiles before submitting a job
+type InputCheck int8
+
+const (
+	InputCheckSkip InputCheck = iota // pass InputFiles to hadoop unchecked (the default)
+	InputCheckWarn                   // log and drop input patterns that match no files
+	InputCheckFail                   // fail if any input pattern matches no files
+)
+
+// InputSummary describes the files matched by Runner.InputFiles
+type InputSummary struct {
+	Files   []*mrfs.FileInfo
+	Bytes   int64
+	Missing []string // patterns that matched no files
+}
+
+func (s *InputSummary) String() string {
+	return fmt.Sprintf("%d files %s", len(s.Files), formatBytes(s.Bytes))
+}
+
+// ExpandInputs expands the patterns in InputFiles to the files they match.
+// Directories are expanded to the files they contain and, as with hadoop,
+// files starting with "_" or "." are ignored.
+func (r *Runner) ExpandInputs() (*InputSummary, error) {
+	ctx := context.Background()
+	s := &InputSummary{}
+	for _, pattern := range r.InputFiles {
+		files, err := expandInput(ctx, hdfs.AbsolutePath(pattern, r.defaultProto))
+		if err != nil {
+			return nil, fmt.Errorf("failed expanding input %s %w", pattern, err)
+		}
+		if len(files) == 0 {
+			s.Missing = append(s.Missing, pattern)
+		}
+		for _, f := range files {
+			s.Files = append(s.Files, f)
+			s.Bytes += f.Size()
+		}
+	}
+	return s, nil
+}
+
+func expandInput(ctx context.Context, pattern string) ([]*mrfs.FileInfo, error) {
+	matches, err := mrfs.Glob(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	var files []*mrfs.FileInfo
+	for _, f := range matches {
+		if isHiddenFile(f.Name()) {
+			continue
+		}
+		if !f.IsDir() {
+			files = append(files, f)
+			continue
+		}
+		contents, err := mrfs.Glob(ctx, strings.TrimSuffix(f.Path(), "/")+"/*")
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range contents {
+			if !c.IsDir() && !isHiddenFile(c.Name()) {
+				files = append(files, c)
+			}
+		}
+	}
+	return files, nil
+}
+
+func isHiddenFile(name string) bool {
+	return strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".")
+}
+
+// checkInputs applies InputCheck to InputFiles. With InputCheckWarn the
+// patterns that match files are used as the input of the first step; InputFiles
+// is not changed.
+func (r *Runner) checkInputs() error {
+	r.inputs, r.inputFiles = nil, nil
+	if r.InputCheck == InputCheckSkip {
+		return nil
+	}
+	summary, err := r.ExpandInputs()
+	if err != nil {
+		return err
+	}
+	if len(summary.Missing) > 0 {
+		if r.InputCheck == InputCheckFail {
+			return fmt.Errorf("input files not found: %s", strings.Join(summary.Missing, ", "))
+		}
+		var inputs []string
+		for _, pattern := range r.InputFiles {
+			if !slices.Contains(summary.Missing, pattern) {
+				inputs = append(inputs, pattern)
+			}
+		}
+		log.Printf("WARNING: skipping missing input files: %s", strings.Join(summary.Missing, ", "))
+		r.inputFiles = inputs
+	}
+	if len(summary.Files) == 0 {
+		return errors.New("no input files found")
+	}
+	log.Printf("input: %s", summary)
+	r.inputs = summary
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,42 @@
+package gomrjob
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.json":           "12345",
+		"b.json":           "123",
+		"logs/c.json":      "1",
+		"logs/_SUCCESS":    "",
+		"logs/.c.json.crc": "12",
+	} {
+		p := filepath.Join(dir, name)
+		assert.Equal(t, os.MkdirAll(filepath.Dir(p), 0755), nil)
+		assert.Equal(t, os.WriteFile(p, []byte(data), 0644), nil)
+	}
+
+	r := NewRunner()
+	r.InputFiles = []string{"file://" + dir + "/*.json", "file://" + dir + "/logs", "file://" + dir + "/missing*"}
+	s, err := r.ExpandInputs()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(s.Files), 3)
+	assert.Equal(t, s.Bytes, int64(9))
+	assert.Equal(t, s.Missing, []string{"file://" + dir + "/missing*"})
+
+	// inputs are not checked by default
+	assert.Equal(t, r.checkInputs(), nil)
+	assert.Equal(t, r.inputs, (*InputSummary)(nil))
+	r.InputCheck = InputCheckFail
+	assert.NotEqual(t, r.checkInputs(), nil)
+	r.InputCheck = InputCheckWarn
+	assert.Equal(t, r.checkInputs(), nil)
+	assert.Equal(t, r.inputFiles, []string{"file://" + dir + "/*.json", "file://" + dir + "/logs"})
+	assert.Equal(t, len(r.InputFiles), 3)
+}
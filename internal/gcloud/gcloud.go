@@ -27,3 +27,9 @@ func LoadFromServiceJSON(serviceAccountPath string, scope ...string) (*http.Clie
 	}
 	return conf.Client(context.Background()), nil
 }
+
+// DefaultClient returns a http.Client using Application Default Credentials
+// (i.e. the Compute Engine metadata server when running on Dataproc workers)
+func DefaultClient(scope ...string) (*http.Client, error) {
+	return google.DefaultClient(context.Background(), scope...)
+}
@@ -9,12 +9,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
 )
 
-const APIBase = "https://www.googleapis.com"
+// APIBase is the Google Storage API endpoint; it can be changed to use a fake (see gcpfake)
+var APIBase = "https://www.googleapis.com"
 
 // Insert using the "Simple Media" API
 // https://cloud.google.com/storage/docs/json_api/v1/how-tos/simple-upload
@@ -46,7 +48,7 @@ type Object struct {
 	ID           string `json:"id"`
 	Bucket       string `json:"bucket"`
 	Name         string `json:"name"`
-	Size         int64  `json:"size"`
+	Size         int64  `json:"size,string"`
 	Created      string `json:"timeCreated"`
 	StorageClass string `json:"storageClass"`
 	MD5Hash      string `json:"md5Hash"`
@@ -64,29 +66,102 @@ type listResp struct {
 // https://cloud.google.com/storage/docs/json_api/v1/objects/list
 // GET https://www.googleapis.com/storage/v1/b/bucket/o?prefix=....
 func List(ctx context.Context, c *http.Client, bucket, prefix, token string) (items []Object, pageToken string, err error) {
-	params := url.Values{"prefix": []string{prefix}}
+	o, err := list(ctx, c, bucket, url.Values{"prefix": []string{prefix}}, token)
+	if err != nil {
+		return nil, "", err
+	}
+	return o.Items, o.NextPageToken, nil
+}
+
+// ListDir returns up to 1k items directly under prefix, and the prefixes (ending
+// in "/") of any "subdirectories"
+func ListDir(ctx context.Context, c *http.Client, bucket, prefix, token string) (items []Object, prefixes []string, pageToken string, err error) {
+	o, err := list(ctx, c, bucket, url.Values{"prefix": []string{prefix}, "delimiter": []string{"/"}}, token)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return o.Items, o.Prefixes, o.NextPageToken, nil
+}
+
+func list(ctx context.Context, c *http.Client, bucket string, params url.Values, token string) (*listResp, error) {
 	if token != "" {
 		params.Set("pageToken", token)
 	}
 	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o?%s", APIBase, url.PathEscape(bucket), params.Encode())
 	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	req = req.WithContext(ctx)
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return nil, "", fmt.Errorf("got status code %d on list of gs://%s/%s", resp.StatusCode, bucket, prefix)
+		return nil, fmt.Errorf("got status code %d on list of gs://%s/%s", resp.StatusCode, bucket, params.Get("prefix"))
 	}
 	var o listResp
 	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
-		return nil, "", err
+		return nil, err
 	}
-	return o.Items, o.NextPageToken, nil
+	return &o, nil
+}
+
+// Get returns the metadata for an object. Errors wrap fs.ErrNotExist if the object does not exist
+// https://cloud.google.com/storage/docs/json_api/v1/objects/get
+func Get(ctx context.Context, c *http.Client, bucket, name string) (*Object, error) {
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s", APIBase, url.PathEscape(bucket), url.PathEscape(name))
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := statusError(resp, "get", bucket, name); err != nil {
+		return nil, err
+	}
+	var o Object
+	if err := json.NewDecoder(resp.Body).Decode(&o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// Open returns the contents of an object. Errors wrap fs.ErrNotExist if the object does not exist
+// https://cloud.google.com/storage/docs/json_api/v1/objects/get (alt=media)
+func Open(ctx context.Context, c *http.Client, bucket, name string) (io.ReadCloser, error) {
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", APIBase, url.PathEscape(bucket), url.PathEscape(name))
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := statusError(resp, "read", bucket, name); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func statusError(resp *http.Response, op, bucket, name string) error {
+	switch resp.StatusCode {
+	case 200:
+		return nil
+	case 404:
+		return fmt.Errorf("%s gs://%s/%s: %w", op, bucket, name, fs.ErrNotExist)
+	case 403:
+		return fmt.Errorf("%s gs://%s/%s: %w", op, bucket, name, fs.ErrPermission)
+	}
+	return fmt.Errorf("got status code %d on %s of gs://%s/%s", resp.StatusCode, op, bucket, name)
 }
 
 // Delete
@@ -104,10 +179,10 @@ func Delete(ctx context.Context, c *http.Client, bucket, name string) error {
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("got status code %d on delete of gs://%s/%s", resp.StatusCode, bucket, name)
+	if resp.StatusCode == 204 {
+		return nil
 	}
-	return nil
+	return statusError(resp, "delete", bucket, name)
 }
 
 func DeletePrefix(ctx context.Context, c *http.Client, bucket, prefix string) error {
@@ -115,19 +190,18 @@ func DeletePrefix(ctx context.Context, c *http.Client, bucket, prefix string) er
 	if err != nil {
 		return err
 	}
-	if len(items) == 0 {
-		return nil
-	}
-	for token != "" {
+	for {
 		for _, obj := range items {
 			if err := Delete(ctx, c, bucket, obj.Name); err != nil {
 				return err
 			}
 		}
+		if token == "" {
+			return nil
+		}
 		items, token, err = List(ctx, c, bucket, prefix, token)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
 }
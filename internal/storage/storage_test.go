@@ -0,0 +1,63 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob/gcpfake"
+	"github.com/jehiah/gomrjob/internal/storage"
+)
+
+func TestStorage(t *testing.T) {
+	s := gcpfake.NewServer(t)
+	s.PageSize = 2
+	c, ctx := s.Client(), context.Background()
+	for _, name := range []string{"dir/a", "dir/b", "dir/sub/c", "other"} {
+		if err := storage.Insert(ctx, c, "bucket", name, "text/plain", strings.NewReader("data "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	obj, err := storage.Get(ctx, c, "bucket", "dir/sub/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Name != "dir/sub/c" || obj.Size != 14 {
+		t.Errorf("unexpected object %#v", obj)
+	}
+	rc, err := storage.Open(ctx, c, "bucket", "dir/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "data dir/a" {
+		t.Errorf("got %q", data)
+	}
+	if _, err := storage.Open(ctx, c, "bucket", "missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v expected fs.ErrNotExist", err)
+	}
+
+	items, token, err := storage.List(ctx, c, "bucket", "dir/", "")
+	if err != nil || len(items) != 2 || token == "" {
+		t.Fatalf("got %d items token %q err %v", len(items), token, err)
+	}
+	items, token, err = storage.List(ctx, c, "bucket", "dir/", token)
+	if err != nil || len(items) != 1 || items[0].Name != "dir/sub/c" || token != "" {
+		t.Fatalf("got %v token %q err %v", items, token, err)
+	}
+
+	if err := storage.DeletePrefix(ctx, c, "bucket", "dir/"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Objects("bucket"); len(got) != 1 || got[0] != "other" {
+		t.Errorf("got %q after DeletePrefix", got)
+	}
+	if err := storage.Delete(ctx, c, "bucket", "dir/a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v expected fs.ErrNotExist", err)
+	}
+}
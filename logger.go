@@ -0,0 +1,114 @@
+package gomrjob
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+var logLevel = flag.String("log-level", "info", "minimum level for gomrjob.Logger (debug, info, warn, error)")
+
+// levelWriter is implemented by writers that carry a log level with each line
+type levelWriter interface {
+	WriteLevel(level string, b []byte) (int, error)
+}
+
+type logOutput struct{ w io.Writer }
+
+var taskLogOutput atomic.Value // logOutput
+
+func init() {
+	taskLogOutput.Store(logOutput{os.Stderr})
+}
+
+// setTaskLogOutput sets where Logger output is written
+func setTaskLogOutput(w io.Writer) {
+	taskLogOutput.Store(logOutput{w})
+}
+
+// Logger returns a leveled, structured logger for use in map reduce steps.
+//
+// Records include the task context (stage, step, the input file and the task
+// attempt) and are sent to the remote logger when Run has configured one, or
+// stderr otherwise. The minimum level is set by --log-level which can be passed
+// to tasks in Runner.PassThroughOptions. Each error is also counted in the
+// "gomrjob" counter group.
+func Logger() *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	out := &handlerOutput{}
+	var h slog.Handler = &taskHandler{
+		inner: slog.NewTextHandler(out, &slog.HandlerOptions{Level: level}),
+		out:   out,
+	}
+	return slog.New(h).With(taskAttrs()...)
+}
+
+// taskAttrs returns the attributes describing the running task
+func taskAttrs() []any {
+	var attrs []any
+	if *stage != "" {
+		attrs = append(attrs, slog.String("stage", *stage), slog.Int("step", *step))
+	}
+	for _, env := range []struct{ key, name string }{
+		{"input", "mapreduce_map_input_file"},
+		{"input", "map_input_file"},
+		{"attempt", "mapreduce_task_attempt_id"},
+	} {
+		if v := os.Getenv(env.name); v != "" {
+			attrs = append(attrs, slog.String(env.key, v))
+			if env.key == "input" {
+				break
+			}
+		}
+	}
+	return attrs
+}
+
+// taskHandler wraps a slog.TextHandler to forward the level of each record to
+// the output and to count errors
+type taskHandler struct {
+	inner slog.Handler
+	out   *handlerOutput
+}
+
+type handlerOutput struct {
+	mu    sync.Mutex
+	level slog.Level
+}
+
+func (o *handlerOutput) Write(b []byte) (int, error) {
+	w := taskLogOutput.Load().(logOutput).w
+	if lw, ok := w.(levelWriter); ok {
+		return lw.WriteLevel(o.level.String(), b)
+	}
+	return w.Write(b)
+}
+
+func (h *taskHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *taskHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		Counter("gomrjob", "log errors", 1)
+	}
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+	h.out.level = r.Level
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *taskHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &taskHandler{inner: h.inner.WithAttrs(attrs), out: h.out}
+}
+
+func (h *taskHandler) WithGroup(name string) slog.Handler {
+	return &taskHandler{inner: h.inner.WithGroup(name), out: h.out}
+}
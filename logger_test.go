@@ -0,0 +1,42 @@
+package gomrjob
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type levelBuffer struct {
+	levels []string
+	lines  []string
+}
+
+func (b *levelBuffer) Write(p []byte) (int, error) {
+	return b.WriteLevel("", p)
+}
+
+func (b *levelBuffer) WriteLevel(level string, p []byte) (int, error) {
+	b.levels = append(b.levels, level)
+	b.lines = append(b.lines, string(p))
+	return len(p), nil
+}
+
+func TestLogger(t *testing.T) {
+	var out levelBuffer
+	setTaskLogOutput(&out)
+	defer setTaskLogOutput(os.Stderr)
+	*stage = "reducer"
+	defer func() { *stage = "" }()
+	t.Setenv("mapreduce_task_attempt_id", "attempt_1_0001_r_000001_0")
+
+	logger := Logger()
+	logger.Debug("not logged")
+	logger.Info("hello", "key", "a")
+	logger.Error("failed")
+
+	assert.Equal(t, out.levels, []string{"INFO", "ERROR"})
+	assert.Equal(t, len(out.lines), 2)
+	assert.True(t, strings.Contains(out.lines[0], `level=INFO msg=hello stage=reducer step=0 attempt=attempt_1_0001_r_000001_0 key=a`), out.lines[0])
+}
@@ -0,0 +1,181 @@
+package gomrjob
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MetricsExporter exports job counters (including the CPU time recorded for
+// each task) and step timings after each step of Run.
+type MetricsExporter struct {
+	// File is replaced with metrics in the OpenMetrics text format
+	File string
+
+	// PushURL is the base URL of a Prometheus Pushgateway (i.e.
+	// "http://pushgateway:9091"). Metrics are pushed to <PushURL>/metrics/job/<Job>
+	PushURL string
+	Client  *http.Client // defaults to http.DefaultClient
+
+	Job string // the job label; defaults to Runner.Name
+}
+
+// Export writes metrics for the steps run so far
+func (m *MetricsExporter) Export(name string, steps []StepEvent) error {
+	job := m.Job
+	if job == "" {
+		job = name
+	}
+	if m.File != "" {
+		var b bytes.Buffer
+		writeMetrics(&b, job, steps, true)
+		if err := writeFileAtomic(m.File, b.Bytes()); err != nil {
+			return err
+		}
+	}
+	if m.PushURL != "" {
+		var b bytes.Buffer
+		writeMetrics(&b, job, steps, false)
+		if err := m.push(job, &b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MetricsExporter) push(job string, body io.Reader) error {
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	endpoint := fmt.Sprintf("%s/metrics/job/%s", strings.TrimSuffix(m.PushURL, "/"), url.PathEscape(job))
+	req, err := http.NewRequest("PUT", endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("got status code %d pushing metrics to %s", resp.StatusCode, endpoint)
+	}
+	return nil
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// cpuTimeRe matches the counters written by auditCpuTime
+var cpuTimeRe = regexp.MustCompile(`^(\w+)\[(\d+)\] (user|system)Time \(ms\)$`)
+
+type metricFamily struct {
+	name, typ, help string
+	samples         []string
+}
+
+func (f *metricFamily) add(labels string, value string) {
+	sample := f.name
+	if f.typ == "counter" {
+		sample += "_total"
+	}
+	f.samples = append(f.samples, fmt.Sprintf("%s{%s} %s", sample, labels, value))
+}
+
+// writeMetrics writes metrics in the OpenMetrics format, or when openMetrics
+// is false the Prometheus text format (version 0.0.4)
+func writeMetrics(w io.Writer, job string, steps []StepEvent, openMetrics bool) {
+	counters := &metricFamily{name: "gomrjob_counter", typ: "counter", help: "Hadoop job counters."}
+	cpu := &metricFamily{name: "gomrjob_task_cpu_seconds", typ: "counter", help: "CPU time used by map reduce tasks."}
+	duration := &metricFamily{name: "gomrjob_step_duration_seconds", typ: "gauge", help: "Time from submission to completion of a step."}
+	success := &metricFamily{name: "gomrjob_step_success", typ: "gauge", help: "1 if the step completed successfully."}
+	failedTasks := &metricFamily{name: "gomrjob_step_failed_tasks", typ: "gauge", help: "Task attempts that failed while running a step."}
+
+	for _, e := range steps {
+		stepLabels := labels("job", job, "step", strconv.Itoa(e.Step))
+		duration.add(stepLabels, strconv.FormatFloat(e.Duration.Seconds(), 'f', -1, 64))
+		ok := "1"
+		if e.Err != nil {
+			ok = "0"
+		}
+		success.add(stepLabels, ok)
+		failedTasks.add(stepLabels, strconv.Itoa(e.Progress.FailedTasks))
+
+		var groups []string
+		for group := range e.Counters {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+		for _, group := range groups {
+			var names []string
+			for name := range e.Counters[group] {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				value := e.Counters[group][name]
+				if m := cpuTimeRe.FindStringSubmatch(name); group == "gomrjob" && m != nil {
+					cpu.add(labels("job", job, "step", m[2], "stage", m[1], "mode", m[3]), strconv.FormatFloat(float64(value)/1000, 'f', -1, 64))
+					continue
+				}
+				counters.add(labels("job", job, "step", strconv.Itoa(e.Step), "group", group, "name", name), strconv.FormatInt(value, 10))
+			}
+		}
+	}
+
+	for _, f := range []*metricFamily{counters, cpu, duration, success, failedTasks} {
+		if len(f.samples) == 0 {
+			continue
+		}
+		family := f.name
+		if !openMetrics && f.typ == "counter" {
+			family += "_total"
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", family, f.typ)
+		fmt.Fprintf(w, "# HELP %s %s\n", family, f.help)
+		for _, s := range f.samples {
+			fmt.Fprintln(w, s)
+		}
+	}
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats key value pairs as name="value",...
+func labels(kv ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, kv[i], labelEscaper.Replace(kv[i+1]))
+	}
+	return b.String()
+}
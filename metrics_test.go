@@ -0,0 +1,80 @@
+package gomrjob
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/stretchr/testify/assert"
+)
+
+var metricsSteps = []StepEvent{
+	{
+		Step:     0,
+		Duration: 90 * time.Second,
+		Counters: hdfs.Counters{
+			"gomrjob": {"mapper[0] userTime (ms)": 1500, "mapper[0] systemTime (ms)": 20},
+			"app":     {`lines "read"`: 10},
+		},
+	},
+	{Step: 1, Duration: time.Second, Err: errors.New("failed"), Progress: hdfs.Progress{FailedTasks: 2}},
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var b strings.Builder
+	writeMetrics(&b, "job", metricsSteps, true)
+	expected := `# TYPE gomrjob_counter counter
+# HELP gomrjob_counter Hadoop job counters.
+gomrjob_counter_total{job="job",step="0",group="app",name="lines \"read\""} 10
+# TYPE gomrjob_task_cpu_seconds counter
+# HELP gomrjob_task_cpu_seconds CPU time used by map reduce tasks.
+gomrjob_task_cpu_seconds_total{job="job",step="0",stage="mapper",mode="system"} 0.02
+gomrjob_task_cpu_seconds_total{job="job",step="0",stage="mapper",mode="user"} 1.5
+# TYPE gomrjob_step_duration_seconds gauge
+# HELP gomrjob_step_duration_seconds Time from submission to completion of a step.
+gomrjob_step_duration_seconds{job="job",step="0"} 90
+gomrjob_step_duration_seconds{job="job",step="1"} 1
+# TYPE gomrjob_step_success gauge
+# HELP gomrjob_step_success 1 if the step completed successfully.
+gomrjob_step_success{job="job",step="0"} 1
+gomrjob_step_success{job="job",step="1"} 0
+# TYPE gomrjob_step_failed_tasks gauge
+# HELP gomrjob_step_failed_tasks Task attempts that failed while running a step.
+gomrjob_step_failed_tasks{job="job",step="0"} 0
+gomrjob_step_failed_tasks{job="job",step="1"} 2
+# EOF
+`
+	assert.Equal(t, b.String(), expected)
+}
+
+func TestMetricsExporter(t *testing.T) {
+	var path, contentType, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.EscapedPath(), r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer ts.Close()
+
+	file := filepath.Join(t.TempDir(), "job.prom")
+	m := &MetricsExporter{File: file, PushURL: ts.URL}
+	err := m.Export("my job", metricsSteps)
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, path, "/metrics/job/my%20job")
+	assert.Equal(t, contentType, "text/plain; version=0.0.4")
+	assert.Contains(t, body, "# TYPE gomrjob_counter_total counter\n")
+	assert.NotContains(t, body, "# EOF")
+
+	b, err := os.ReadFile(file)
+	assert.Equal(t, err, nil)
+	assert.Contains(t, string(b), `gomrjob_step_success{job="my job",step="1"} 0`)
+	assert.True(t, strings.HasSuffix(string(b), "# EOF\n"))
+}
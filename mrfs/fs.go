@@ -0,0 +1,220 @@
+// Package mrfs provides uniform access to the files a map reduce job reads and
+// writes, regardless of where they are stored.
+//
+// Paths are URIs and are dispatched by scheme:
+//
+//	hdfs:///path, s3://bucket/path  via the `hadoop fs` CLI
+//	gs://bucket/path                via the Google Storage JSON API (see GoogleStorage)
+//	file:///path                    on the local filesystem
+//
+// Paths without a scheme are treated as hdfs:/// paths, consistent with how
+// hadoop-streaming.jar arguments are qualified.
+package mrfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+// ErrUnsupportedScheme is returned for paths with a scheme that has no registered FileSystem
+var ErrUnsupportedScheme = errors.New("mrfs: unsupported scheme")
+
+// FileSystem is implemented by each storage backend. All names are fully qualified URIs.
+type FileSystem interface {
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+	Stat(ctx context.Context, name string) (*FileInfo, error)
+	Glob(ctx context.Context, pattern string) ([]*FileInfo, error)
+	Remove(ctx context.Context, name string) error
+	RemoveAll(ctx context.Context, name string) error
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]FileSystem{
+		"hdfs": Hadoop{},
+		"s3":   Hadoop{},
+		"file": Local{},
+	}
+)
+
+// Register sets the FileSystem used for paths with the given scheme (i.e. "gs").
+// A nil fsys removes the registration.
+func Register(scheme string, fsys FileSystem) {
+	mu.Lock()
+	defer mu.Unlock()
+	if fsys == nil {
+		delete(registry, scheme)
+		return
+	}
+	registry[scheme] = fsys
+}
+
+// Lookup returns the FileSystem for name and the fully qualified form of name
+func Lookup(name string) (FileSystem, string, error) {
+	name = hdfs.AbsolutePath(name, "")
+	scheme, _, _ := strings.Cut(name, "://")
+	mu.RLock()
+	fsys, ok := registry[scheme]
+	mu.RUnlock()
+	if !ok {
+		return nil, name, fmt.Errorf("%w %q for %s", ErrUnsupportedScheme, scheme, name)
+	}
+	return fsys, name, nil
+}
+
+// Open returns a reader for the contents of name
+func Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	fsys, name, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(ctx, name)
+}
+
+// Create returns a writer to a new file at name, replacing any existing file.
+// Data is not guaranteed to be stored until Close returns without error.
+func Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	fsys, name, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Create(ctx, name)
+}
+
+// Stat returns a FileInfo for name. Errors wrap fs.ErrNotExist if name does not exist
+func Stat(ctx context.Context, name string) (*FileInfo, error) {
+	fsys, name, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Stat(ctx, name)
+}
+
+// Glob returns the files matching pattern (using path.Match syntax), sorted by path.
+// As with hadoop, "{a,b}" matches either alternative. No match is not an error.
+func Glob(ctx context.Context, pattern string) ([]*FileInfo, error) {
+	fsys, pattern, err := Lookup(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fsys.(Hadoop); ok {
+		return fsys.Glob(ctx, pattern) // `hadoop fs` expands braces itself
+	}
+	patterns := expandBraces(pattern)
+	if len(patterns) == 1 {
+		return fsys.Glob(ctx, pattern)
+	}
+	var o []*FileInfo
+	seen := make(map[string]bool)
+	for _, p := range patterns {
+		files, err := fsys.Glob(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !seen[f.path] {
+				seen[f.path] = true
+				o = append(o, f)
+			}
+		}
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].path < o[j].path })
+	return o, nil
+}
+
+// expandBraces expands each "{a,b}" (which may be nested) in pattern into a
+// pattern for each alternative. Unmatched braces are left as is.
+func expandBraces(pattern string) []string {
+	start, depth := -1, 0
+	var commas []int
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+				commas = commas[:0]
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			var o []string
+			prev := start
+			for _, c := range append(commas, i) {
+				alt := pattern[:start] + pattern[prev+1:c] + pattern[i+1:]
+				o = append(o, expandBraces(alt)...)
+				prev = c
+			}
+			return o
+		}
+	}
+	return []string{pattern}
+}
+
+// Remove removes a single file
+func Remove(ctx context.Context, name string) error {
+	fsys, name, err := Lookup(name)
+	if err != nil {
+		return err
+	}
+	return fsys.Remove(ctx, name)
+}
+
+// RemoveAll removes name and anything it contains. It is not an error if name does not exist.
+func RemoveAll(ctx context.Context, name string) error {
+	fsys, name, err := Lookup(name)
+	if err != nil {
+		return err
+	}
+	return fsys.RemoveAll(ctx, name)
+}
+
+// FileInfo describes a file and implements fs.FileInfo
+type FileInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+	dir     bool
+	sys     interface{}
+}
+
+// Path returns the fully qualified URI of the file
+func (f *FileInfo) Path() string       { return f.path }
+func (f *FileInfo) Name() string       { return path.Base(f.path) }
+func (f *FileInfo) Size() int64        { return f.size }
+func (f *FileInfo) ModTime() time.Time { return f.modTime }
+func (f *FileInfo) IsDir() bool        { return f.dir }
+func (f *FileInfo) Sys() interface{}   { return f.sys }
+func (f *FileInfo) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// hasMeta reports whether p contains any of the magic characters recognized by path.Match
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
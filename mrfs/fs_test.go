@@ -0,0 +1,150 @@
+package mrfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+	w, err := Create(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	type testCase struct {
+		name, expect string
+		err          error
+	}
+	tests := []testCase{
+		{"/a/b", "hdfs:///a/b", nil},
+		{"file:///tmp/a", "file:///tmp/a", nil},
+		{"s3://bucket/a", "s3://bucket/a", nil},
+		{"gs://bucket/a", "gs://bucket/a", ErrUnsupportedScheme},
+	}
+	for i, tc := range tests {
+		_, got, err := Lookup(tc.name)
+		if got != tc.expect {
+			t.Errorf("test[%d] got %q expected %q", i, got, tc.expect)
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("test[%d] got err %v expected %v", i, err, tc.err)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	root := "file://" + t.TempDir()
+	writeFile(t, root+"/output/part-00000", "a\t1\n")
+	writeFile(t, root+"/output/part-00001", "b\t2\n")
+	writeFile(t, root+"/output/_SUCCESS", "")
+
+	files, err := Glob(ctx, root+"/output/part-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files expected 2", len(files))
+	}
+	if got, expect := files[1].Path(), root+"/output/part-00001"; got != expect {
+		t.Errorf("got %q expected %q", got, expect)
+	}
+	if files[0].Size() != 4 {
+		t.Errorf("got size %d expected 4", files[0].Size())
+	}
+
+	rc, err := Open(ctx, files[0].Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "a\t1\n" {
+		t.Errorf("got %q", data)
+	}
+
+	fi, err := Stat(ctx, root+"/output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() {
+		t.Errorf("expected %s to be a directory", fi.Path())
+	}
+
+	if err := RemoveAll(ctx, root+"/output"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Stat(ctx, root+"/output"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("got %v expected fs.ErrNotExist", err)
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	type testCase struct {
+		pattern string
+		expect  []string
+	}
+	tests := []testCase{
+		{"gs://b/logs/*", []string{"gs://b/logs/*"}},
+		{"gs://b/logs/{2024,2025}/*", []string{"gs://b/logs/2024/*", "gs://b/logs/2025/*"}},
+		{"{a,b}/{c,d}", []string{"a/c", "a/d", "b/c", "b/d"}},
+		{"x{a,b{c,d}}", []string{"xa", "xbc", "xbd"}},
+		{"x{a}", []string{"xa"}},
+		{"x{a,b", []string{"x{a,b"}},
+		{`x\{a,b}`, []string{`x\{a,b}`}},
+	}
+	for i, tc := range tests {
+		got := expandBraces(tc.pattern)
+		if strings.Join(got, " ") != strings.Join(tc.expect, " ") {
+			t.Errorf("test[%d] got %q expected %q for %q", i, got, tc.expect, tc.pattern)
+		}
+	}
+}
+
+func TestGlobBraces(t *testing.T) {
+	ctx := context.Background()
+	root := "file://" + t.TempDir()
+	writeFile(t, root+"/logs/2024/a", "a")
+	writeFile(t, root+"/logs/2025/b", "b")
+	writeFile(t, root+"/logs/2026/c", "c")
+
+	files, err := Glob(ctx, root+"/logs/{2024,2025,202*}/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, strings.TrimPrefix(f.Path(), root))
+	}
+	if got, expect := strings.Join(names, " "), "/logs/2024/a /logs/2025/b /logs/2026/c"; got != expect {
+		t.Errorf("got %q expected %q", got, expect)
+	}
+}
+
+func TestSub(t *testing.T) {
+	root := "file://" + t.TempDir()
+	writeFile(t, root+"/a.txt", "a")
+	writeFile(t, root+"/dir/b.txt", "bb")
+
+	fsys, err := Sub(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "a.txt", "dir/b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
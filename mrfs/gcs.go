@@ -0,0 +1,209 @@
+package mrfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jehiah/gomrjob/internal/storage"
+)
+
+// GoogleStorage accesses gs:// paths using the Google Storage JSON API. It is
+// not registered by default; Runner registers one when a service account is
+// configured, or use
+//
+//	mrfs.Register("gs", mrfs.GoogleStorage{Client: client})
+type GoogleStorage struct {
+	Client *http.Client // an authenticated client (see golang.org/x/oauth2/google)
+}
+
+func splitGS(name string) (bucket, object string, err error) {
+	rest, ok := strings.CutPrefix(name, "gs://")
+	if !ok {
+		return "", "", fmt.Errorf("invalid Google Storage path %q", name)
+	}
+	bucket, object, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("missing bucket in %q", name)
+	}
+	return bucket, object, nil
+}
+
+func (g GoogleStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	bucket, object, err := splitGS(name)
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(ctx, g.Client, bucket, object)
+}
+
+func (g GoogleStorage) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	bucket, object, err := splitGS(name)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	w := &gsWriter{PipeWriter: pw, done: make(chan error, 1)}
+	go func() {
+		err := storage.Insert(ctx, g.Client, bucket, object, "", pr)
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+type gsWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *gsWriter) Close() error {
+	w.PipeWriter.Close()
+	return <-w.done
+}
+
+// Stat returns the object name; if there is no such object but there are objects
+// under the "name/" prefix it is reported as a directory.
+func (g GoogleStorage) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	bucket, object, err := splitGS(name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(object, "/") == "" {
+		return &FileInfo{path: "gs://" + bucket, dir: true}, nil
+	}
+	obj, err := storage.Get(ctx, g.Client, bucket, object)
+	if err == nil {
+		return gsFileInfo(obj), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	items, _, listErr := storage.List(ctx, g.Client, bucket, strings.TrimSuffix(object, "/")+"/", "")
+	if listErr != nil {
+		return nil, listErr
+	}
+	if len(items) == 0 {
+		return nil, err
+	}
+	return &FileInfo{path: name, dir: true}, nil
+}
+
+// Glob lists objects under the longest literal prefix of pattern and matches
+// each with path.Match, so as with `hadoop fs` a '*' does not match across '/'
+func (g GoogleStorage) Glob(ctx context.Context, pattern string) ([]*FileInfo, error) {
+	bucket, object, err := splitGS(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if !hasMeta(object) {
+		fi, err := g.Stat(ctx, pattern)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*FileInfo{fi}, nil
+	}
+	prefix := object
+	if i := strings.IndexAny(object, `*?[\`); i != -1 {
+		prefix = object[:i]
+	}
+	var o []*FileInfo
+	var token string
+	for {
+		var items []storage.Object
+		items, token, err = storage.List(ctx, g.Client, bucket, prefix, token)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			if ok, err := path.Match(object, items[i].Name); err != nil {
+				return nil, err
+			} else if ok {
+				o = append(o, gsFileInfo(&items[i]))
+			}
+		}
+		if token == "" {
+			break
+		}
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].path < o[j].path })
+	return o, nil
+}
+
+// ReadDir returns the objects directly under name and, as directories, the
+// prefixes of any objects nested further below it
+func (g GoogleStorage) ReadDir(ctx context.Context, name string) ([]*FileInfo, error) {
+	bucket, object, err := splitGS(name)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.TrimSuffix(object, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	var o []*FileInfo
+	var token string
+	for {
+		items, prefixes, next, err := storage.ListDir(ctx, g.Client, bucket, prefix, token)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			if items[i].Name != prefix {
+				o = append(o, gsFileInfo(&items[i]))
+			}
+		}
+		for _, p := range prefixes {
+			o = append(o, &FileInfo{path: "gs://" + bucket + "/" + strings.TrimSuffix(p, "/"), dir: true})
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].path < o[j].path })
+	return o, nil
+}
+
+func (g GoogleStorage) Remove(ctx context.Context, name string) error {
+	bucket, object, err := splitGS(name)
+	if err != nil {
+		return err
+	}
+	return storage.Delete(ctx, g.Client, bucket, object)
+}
+
+func (g GoogleStorage) RemoveAll(ctx context.Context, name string) error {
+	bucket, object, err := splitGS(name)
+	if err != nil {
+		return err
+	}
+	object = strings.TrimSuffix(object, "/")
+	if object != "" {
+		if err := storage.Delete(ctx, g.Client, bucket, object); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		object += "/"
+	}
+	return storage.DeletePrefix(ctx, g.Client, bucket, object)
+}
+
+func gsFileInfo(obj *storage.Object) *FileInfo {
+	modified, _ := time.Parse(time.RFC3339, obj.Updateed)
+	return &FileInfo{
+		path:    fmt.Sprintf("gs://%s/%s", obj.Bucket, obj.Name),
+		size:    obj.Size,
+		modTime: modified,
+		sys:     obj,
+	}
+}
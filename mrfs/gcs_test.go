@@ -0,0 +1,61 @@
+package mrfs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob/gcpfake"
+	"github.com/jehiah/gomrjob/mrfs"
+)
+
+func TestGoogleStorageSub(t *testing.T) {
+	s := gcpfake.NewServer(t)
+	s.PageSize = 2
+	for _, name := range []string{"out/a.txt", "out/dir/b.txt", "out/dir/sub/c.txt", "out/e/f.txt", "other.txt"} {
+		s.PutObject("bucket", name, []byte(name))
+	}
+
+	for _, root := range []string{"gs://bucket/out", "gs://bucket"} {
+		fsys, err := mrfs.Sub(context.Background(), root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var walked []string
+		err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				p += "/"
+			}
+			walked = append(walked, p)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := "./ a.txt dir/ dir/b.txt dir/sub/ dir/sub/c.txt e/ e/f.txt"
+		if root == "gs://bucket" {
+			expect = "./ other.txt out/ out/a.txt out/dir/ out/dir/b.txt out/dir/sub/ out/dir/sub/c.txt out/e/ out/e/f.txt"
+		}
+		if got := strings.Join(walked, " "); got != expect {
+			t.Errorf("%s got %q expected %q", root, got, expect)
+		}
+	}
+}
+
+func TestGoogleStorageRegistration(t *testing.T) {
+	t.Run("fake", func(t *testing.T) {
+		gcpfake.NewServer(t)
+		if _, _, err := mrfs.Lookup("gs://bucket/a"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// the registration is removed when the test using the fake completes
+	if _, _, err := mrfs.Lookup("gs://bucket/a"); !errors.Is(err, mrfs.ErrUnsupportedScheme) {
+		t.Errorf("got err %v expected %v", err, mrfs.ErrUnsupportedScheme)
+	}
+}
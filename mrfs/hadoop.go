@@ -0,0 +1,79 @@
+package mrfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+// Hadoop accesses files with the `hadoop fs` CLI. It is used for hdfs:// and
+// s3:// paths and requires $HADOOP_HOME
+type Hadoop struct {
+	// Quiet disables logging the commands run by Open, Create and Glob (i.e.
+	// when polling, or writing log output)
+	Quiet bool
+}
+
+func (h Hadoop) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if h.Quiet {
+		return hdfs.OpenQuiet(name)
+	}
+	return hdfs.Open(name)
+}
+
+func (h Hadoop) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	if h.Quiet {
+		return hdfs.CreateQuiet(name)
+	}
+	return hdfs.Create(name)
+}
+
+func (Hadoop) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	f, err := hdfs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return hadoopFileInfo(f), nil
+}
+
+func (h Hadoop) Glob(ctx context.Context, pattern string) ([]*FileInfo, error) {
+	glob := hdfs.Glob
+	if h.Quiet {
+		glob = hdfs.GlobQuiet
+	}
+	files, err := glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var o []*FileInfo
+	for _, f := range files {
+		o = append(o, hadoopFileInfo(f))
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].path < o[j].path })
+	return o, nil
+}
+
+func (Hadoop) Remove(ctx context.Context, name string) error {
+	return hdfs.Remove(name)
+}
+
+func (Hadoop) RemoveAll(ctx context.Context, name string) error {
+	err := hdfs.RMR(name)
+	if errors.Is(err, hdfs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func hadoopFileInfo(f *hdfs.HdfsFile) *FileInfo {
+	return &FileInfo{
+		path:    f.Path,
+		size:    f.Size,
+		modTime: f.Modified,
+		dir:     f.IsDir(),
+		sys:     f,
+	}
+}
@@ -0,0 +1,150 @@
+package mrfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// Sub returns an fs.FS for the tree rooted at root (i.e. "gs://bucket/output").
+// The returned value also implements fs.StatFS, fs.GlobFS and fs.ReadDirFS.
+func Sub(ctx context.Context, root string) (fs.FS, error) {
+	fsys, root, err := Lookup(root)
+	if err != nil {
+		return nil, err
+	}
+	return &subFS{ctx: ctx, fsys: fsys, root: strings.TrimSuffix(root, "/")}, nil
+}
+
+// dirReader is implemented by filesystems that can list a directory, including
+// any subdirectories, more accurately than a Glob of "dir/*"
+type dirReader interface {
+	ReadDir(ctx context.Context, name string) ([]*FileInfo, error)
+}
+
+type subFS struct {
+	ctx  context.Context
+	fsys FileSystem
+	root string
+}
+
+func (s *subFS) full(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return s.root, nil
+	}
+	return s.root + "/" + name, nil
+}
+
+func (s *subFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := s.full("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := s.fsys.Stat(s.ctx, full)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return fi, nil
+}
+
+func (s *subFS) Open(name string) (fs.File, error) {
+	full, err := s.full("open", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := s.fsys.Stat(s.ctx, full)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if fi.IsDir() {
+		return &dir{s: s, name: name, info: fi}, nil
+	}
+	rc, err := s.fsys.Open(s.ctx, full)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &file{ReadCloser: rc, info: fi}, nil
+}
+
+func (s *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := s.full("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	var files []*FileInfo
+	if d, ok := s.fsys.(dirReader); ok {
+		files, err = d.ReadDir(s.ctx, full)
+	} else {
+		files, err = s.fsys.Glob(s.ctx, full+"/*")
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	var o []fs.DirEntry
+	for _, fi := range files {
+		o = append(o, fs.FileInfoToDirEntry(fi))
+	}
+	return o, nil
+}
+
+func (s *subFS) Glob(pattern string) ([]string, error) {
+	if _, err := s.full("glob", pattern); err != nil {
+		return nil, err
+	}
+	files, err := s.fsys.Glob(s.ctx, s.root+"/"+pattern)
+	if err != nil {
+		return nil, err
+	}
+	var o []string
+	for _, fi := range files {
+		o = append(o, strings.TrimPrefix(fi.Path(), s.root+"/"))
+	}
+	return o, nil
+}
+
+type file struct {
+	io.ReadCloser
+	info *FileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+type dir struct {
+	s       *subFS
+	name    string
+	info    *FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.s.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.read = entries, true
+	}
+	if n <= 0 || n >= len(d.entries) {
+		o := d.entries
+		d.entries = nil
+		if n > 0 && len(o) == 0 {
+			return nil, io.EOF
+		}
+		return o, nil
+	}
+	o := d.entries[:n]
+	d.entries = d.entries[n:]
+	return o, nil
+}
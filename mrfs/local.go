@@ -0,0 +1,74 @@
+package mrfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Local accesses file:// paths on the local filesystem
+type Local struct{}
+
+func localPath(name string) string {
+	return strings.TrimPrefix(name, "file://")
+}
+
+func (Local) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(localPath(name))
+}
+
+// Create makes any missing parent directories, as `hadoop fs -put` does
+func (Local) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	p := localPath(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(p)
+}
+
+func (Local) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	p := localPath(name)
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	return localFileInfo(p, fi), nil
+}
+
+func (Local) Glob(ctx context.Context, pattern string) ([]*FileInfo, error) {
+	matches, err := filepath.Glob(localPath(pattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var o []*FileInfo
+	for _, p := range matches {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		o = append(o, localFileInfo(p, fi))
+	}
+	return o, nil
+}
+
+func (Local) Remove(ctx context.Context, name string) error {
+	return os.Remove(localPath(name))
+}
+
+func (Local) RemoveAll(ctx context.Context, name string) error {
+	return os.RemoveAll(localPath(name))
+}
+
+func localFileInfo(p string, fi os.FileInfo) *FileInfo {
+	return &FileInfo{
+		path:    "file://" + p,
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+		dir:     fi.IsDir(),
+		sys:     fi,
+	}
+}
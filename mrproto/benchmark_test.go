@@ -0,0 +1,130 @@
+package mrproto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// benchmarkInput returns sorted key\tvalue lines with 10 values for each key
+func benchmarkInput(records int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < records; i++ {
+		fmt.Fprintf(&b, "[\"key\",%d]\t{\"value\":%d,\"name\":\"record %d\",\"tags\":[\"a\",\"b\"]}\n", i/10, i, i)
+	}
+	return b.Bytes()
+}
+
+const benchmarkRecords = 10000
+
+func benchmarkInputProtocol(b *testing.B, input []byte, consume func(io.Reader)) {
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		consume(bytes.NewReader(input))
+	}
+	b.ReportMetric(float64(benchmarkRecords*b.N)/b.Elapsed().Seconds(), "records/s")
+}
+
+func BenchmarkJsonInputProtocol(b *testing.B) {
+	var values bytes.Buffer
+	for i := 0; i < benchmarkRecords; i++ {
+		fmt.Fprintf(&values, "{\"value\":%d,\"name\":\"record %d\",\"tags\":[\"a\",\"b\"]}\n", i, i)
+	}
+	benchmarkInputProtocol(b, values.Bytes(), func(r io.Reader) {
+		for range JsonInputProtocol(r) {
+		}
+	})
+}
+
+func BenchmarkRawInputProtocol(b *testing.B) {
+	benchmarkInputProtocol(b, benchmarkInput(benchmarkRecords), func(r io.Reader) {
+		for range RawInputProtocol(r) {
+		}
+	})
+}
+
+func BenchmarkJsonInternalInputProtocol(b *testing.B) {
+	benchmarkInputProtocol(b, benchmarkInput(benchmarkRecords), func(r io.Reader) {
+		for kv := range JsonInternalInputProtocol(r) {
+			for range kv.Values {
+			}
+		}
+	})
+}
+
+func BenchmarkRawJsonInternalInputProtocol(b *testing.B) {
+	benchmarkInputProtocol(b, benchmarkInput(benchmarkRecords), func(r io.Reader) {
+		for kv := range RawJsonInternalInputProtocol(r) {
+			for range kv.Values {
+			}
+		}
+	})
+}
+
+func BenchmarkRawInternalInputProtocol(b *testing.B) {
+	benchmarkInputProtocol(b, benchmarkInput(benchmarkRecords), func(r io.Reader) {
+		for range RawInternalInputProtocol(r) {
+		}
+	})
+}
+
+func BenchmarkRawInternalChanInputProtocol(b *testing.B) {
+	benchmarkInputProtocol(b, benchmarkInput(benchmarkRecords), func(r io.Reader) {
+		for kv := range RawInternalChanInputProtocol(r) {
+			for range kv.Values {
+			}
+		}
+	})
+}
+
+type benchmarkValue struct {
+	Value int      `json:"value"`
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+}
+
+func benchmarkOutputProtocol(b *testing.B, key func(i int) interface{}, protocol func(io.Writer) (*sync.WaitGroup, chan<- KeyValue)) {
+	b.ReportAllocs()
+	var n int64
+	for i := 0; i < b.N; i++ {
+		w := &countingWriter{}
+		wg, out := protocol(w)
+		for j := 0; j < benchmarkRecords; j++ {
+			out <- KeyValue{key(j), benchmarkValue{j, "record", []string{"a", "b"}}}
+		}
+		close(out)
+		wg.Wait()
+		n = w.n
+	}
+	b.SetBytes(n)
+	b.ReportMetric(float64(benchmarkRecords*b.N)/b.Elapsed().Seconds(), "records/s")
+}
+
+type countingWriter struct{ n int64 }
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func BenchmarkJsonInternalOutputProtocol(b *testing.B) {
+	benchmarkOutputProtocol(b, func(i int) interface{} { return []interface{}{"key", i / 10} }, JsonInternalOutputProtocol)
+}
+
+func BenchmarkRawJsonInternalOutputProtocol(b *testing.B) {
+	benchmarkOutputProtocol(b, func(i int) interface{} { return []byte(fmt.Sprintf("[\"key\",%d]", i/10)) }, RawJsonInternalOutputProtocol)
+}
+
+func BenchmarkSum(b *testing.B) {
+	var values bytes.Buffer
+	for i := 0; i < benchmarkRecords; i++ {
+		fmt.Fprintf(&values, "[\"key\",%d]\t%d\n", i/10, i)
+	}
+	benchmarkInputProtocol(b, values.Bytes(), func(r io.Reader) {
+		Sum(r, io.Discard)
+	})
+}
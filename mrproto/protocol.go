@@ -17,6 +17,7 @@ import (
 func JsonInputProtocol(input io.Reader) <-chan *simplejson.Json {
 	out := make(chan *simplejson.Json, 100)
 	go func() {
+		records := gomrjob.RecordsIn("JsonInputProtocol")
 		var line []byte
 		var lineErr error
 		r := bufio.NewReaderSize(input, 1024*1024*2)
@@ -33,6 +34,7 @@ func JsonInputProtocol(input io.Reader) <-chan *simplejson.Json {
 				gomrjob.Counter("JsonInputProtocol", "invalid line", 1)
 				log.Printf("%s - failed parsing %s", err, line)
 			} else {
+				records.Add(1)
 				out <- data
 			}
 		}
@@ -46,6 +48,7 @@ func JsonInputProtocol(input io.Reader) <-chan *simplejson.Json {
 func RawInputProtocol(input io.Reader) <-chan []byte {
 	out := make(chan []byte, 100)
 	go func() {
+		records := gomrjob.RecordsIn("RawInputProtocol")
 		var line []byte
 		var lineErr error
 		r := bufio.NewReaderSize(input, 1024*1024*2)
@@ -61,6 +64,7 @@ func RawInputProtocol(input io.Reader) <-chan []byte {
 			if len(line) < 1 || lineErr != nil {
 				continue
 			}
+			records.Add(1)
 			out <- line
 		}
 		close(out)
@@ -79,6 +83,7 @@ func JsonInternalInputProtocol(input io.Reader) <-chan JsonKeyChan {
 	out := make(chan JsonKeyChan)
 	var jsonChan chan *simplejson.Json
 	go func() {
+		records := gomrjob.RecordsIn("JsonInternalInputProtocol")
 		var line []byte
 		var lineErr error
 		r := bufio.NewReaderSize(input, 1024*1024*2)
@@ -110,6 +115,7 @@ func JsonInternalInputProtocol(input io.Reader) <-chan JsonKeyChan {
 					continue
 				}
 				lastKey = chunks[0]
+				gomrjob.SetCurrentKey(lastKey)
 
 				jsonChan = make(chan *simplejson.Json, 100)
 				out <- JsonKeyChan{key, jsonChan}
@@ -119,6 +125,7 @@ func JsonInternalInputProtocol(input io.Reader) <-chan JsonKeyChan {
 				gomrjob.Counter("JsonInternalInputProtocol", "invalid line", 1)
 				log.Printf("%s - failed parsing %s", err, line)
 			} else {
+				records.Add(1)
 				jsonChan <- data
 			}
 		}
@@ -141,6 +148,7 @@ func RawJsonInternalInputProtocol(input io.Reader) <-chan RawJsonKeyChan {
 	out := make(chan RawJsonKeyChan)
 	var jsonChan chan *simplejson.Json
 	go func() {
+		records := gomrjob.RecordsIn("RawJsonInternalInputProtocol")
 		var line []byte
 		var lineErr error
 		r := bufio.NewReaderSize(input, 1024*1024*2)
@@ -166,6 +174,7 @@ func RawJsonInternalInputProtocol(input io.Reader) <-chan RawJsonKeyChan {
 					jsonChan = nil
 				}
 				lastKey = chunks[0]
+				gomrjob.SetCurrentKey(lastKey)
 				jsonChan = make(chan *simplejson.Json, 100)
 				out <- RawJsonKeyChan{lastKey, jsonChan}
 			}
@@ -174,6 +183,7 @@ func RawJsonInternalInputProtocol(input io.Reader) <-chan RawJsonKeyChan {
 				gomrjob.Counter("RawJsonInternalInputProtocol", "invalid line", 1)
 				log.Printf("%s - failed parsing %s", err, line)
 			} else {
+				records.Add(1)
 				jsonChan <- data
 			}
 		}
@@ -189,6 +199,7 @@ func RawJsonInternalInputProtocol(input io.Reader) <-chan RawJsonKeyChan {
 func RawInternalInputProtocol(input io.Reader) <-chan KeyValue {
 	out := make(chan KeyValue, 100)
 	go func() {
+		records := gomrjob.RecordsIn("RawInternalInputProtocol")
 		var line []byte
 		var lineErr error
 		r := bufio.NewReaderSize(input, 1024*1024*2)
@@ -208,6 +219,7 @@ func RawInternalInputProtocol(input io.Reader) <-chan KeyValue {
 				lastKey = lastKey[:0]
 				continue
 			}
+			records.Add(1)
 			out <- KeyValue{chunks[0], chunks[1]}
 		}
 		close(out)
@@ -229,6 +241,7 @@ func JsonInternalOutputProtocol(writer io.Writer) (*sync.WaitGroup, chan<- KeyVa
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
+		records := gomrjob.RecordsOut("JsonInternalOutputProtocol")
 		for kv := range in {
 			kBytes, err := json.Marshal(kv.Key)
 			if err != nil {
@@ -246,6 +259,7 @@ func JsonInternalOutputProtocol(writer io.Writer) (*sync.WaitGroup, chan<- KeyVa
 			w.Write(tab)     // nolint:errcheck
 			w.Write(vBytes)  // nolint:errcheck
 			w.Write(newline) // nolint:errcheck
+			records.Add(1)
 		}
 		w.Flush()
 		wg.Done()
@@ -262,6 +276,7 @@ func RawJsonInternalOutputProtocol(writer io.Writer) (*sync.WaitGroup, chan<- Ke
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
+		records := gomrjob.RecordsOut("RawJsonInternalOutputProtocol")
 		for kv := range in {
 			kBytes, ok := kv.Key.([]byte)
 			if !ok {
@@ -279,6 +294,7 @@ func RawJsonInternalOutputProtocol(writer io.Writer) (*sync.WaitGroup, chan<- Ke
 			w.Write(tab)     // nolint:errcheck
 			w.Write(vBytes)  // nolint:errcheck
 			w.Write(newline) // nolint:errcheck
+			records.Add(1)
 		}
 		w.Flush()
 		wg.Done()
@@ -296,6 +312,7 @@ func RawInternalChanInputProtocol(input io.Reader) <-chan RawKeyChan {
 	out := make(chan RawKeyChan)
 	var innerChan chan []byte
 	go func() {
+		records := gomrjob.RecordsIn("RawInternalChanInputProtocol")
 		var line []byte
 		var lineErr error
 		r := bufio.NewReaderSize(input, 1024*1024*2)
@@ -321,9 +338,11 @@ func RawInternalChanInputProtocol(input io.Reader) <-chan RawKeyChan {
 					innerChan = nil
 				}
 				lastKey = chunks[0]
+				gomrjob.SetCurrentKey(lastKey)
 				innerChan = make(chan []byte, 100)
 				out <- RawKeyChan{lastKey, innerChan}
 			}
+			records.Add(1)
 			innerChan <- chunks[1]
 		}
 		if innerChan != nil {
@@ -5,6 +5,7 @@ import (
 	"io"
 	"testing"
 
+	"github.com/jehiah/gomrjob"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,6 +15,8 @@ func TestJsonInputProtocol(t *testing.T) {
 	input := bytes.NewBufferString(`{"_HEARTBEAT_":1359516282.66455, "row": 0}
 not-json-data
 {"row":1}`)
+	records := gomrjob.RecordsIn("JsonInputProtocol")
+	before := records.Load()
 	count := 0
 	for record := range JsonInputProtocol(input) {
 		i, err := record.Get("row").Int()
@@ -22,6 +25,7 @@ not-json-data
 		count += 1
 	}
 	assert.Equal(t, count, 2)
+	assert.Equal(t, records.Load()-before, int64(2))
 }
 
 func TestJsonInternalOutputProtocol(t *testing.T) {
@@ -0,0 +1,245 @@
+package mrtest
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/jehiah/gomrjob/mrfs"
+)
+
+// FakeBackend is a gomrjob.Backend that runs jobs in process so that driver
+// programs (which wrap Runner.Run with retries and output handling) can be
+// tested without a cluster. Paths without a scheme, and files staged by Run,
+// are stored under Dir.
+//
+// While a job runs the environment (Properties and map_input_file) and working
+// directory of the process are changed as hadoop would for a task, so
+// FakeBackend is not safe to use from parallel tests: tests running at the
+// same time observe those changes.
+//
+//	backend := mrtest.NewFakeBackend(t)
+//	runner.Backend = backend
+//	runner.InputFiles = []string{"input/*"} // relative to backend.Dir
+//	err := runner.Run()
+type FakeBackend struct {
+	Dir string
+
+	// SubmitError is called as each job is submitted; a returned error fails
+	// the submission (i.e. dataproc.ErrUnavailable)
+	SubmitError func(j hdfs.Job) error
+
+	// TaskError is called before each map, combine or reduce task of a job; a
+	// returned error fails the job
+	TaskError func(j hdfs.Job, stage string) error
+
+	t      testing.TB
+	mu     sync.Mutex
+	jobs   []hdfs.Job
+	staged []string
+}
+
+// NewFakeBackend returns a FakeBackend with a temporary Dir
+func NewFakeBackend(t testing.TB) *FakeBackend {
+	return &FakeBackend{Dir: t.TempDir(), t: t}
+}
+
+// FailFirst returns a SubmitError func that returns err for the first n submissions
+func FailFirst(n int, err error) func(hdfs.Job) error {
+	var mu sync.Mutex
+	return func(hdfs.Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if n > 0 {
+			n--
+			return err
+		}
+		return nil
+	}
+}
+
+// Jobs returns the jobs submitted, including those that failed
+func (b *FakeBackend) Jobs() []hdfs.Job {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]hdfs.Job(nil), b.jobs...)
+}
+
+// Staged returns the remote paths of files staged by Run
+func (b *FakeBackend) Staged() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.staged...)
+}
+
+func (b *FakeBackend) DefaultProto() string {
+	return "file://" + filepath.ToSlash(b.Dir) + "/"
+}
+
+func (b *FakeBackend) Stage(ctx context.Context, local, remote string) error {
+	b.mu.Lock()
+	b.staged = append(b.staged, remote)
+	b.mu.Unlock()
+	dst := filepath.Join(b.Dir, filepath.FromSlash(remote))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return copyFile(local, dst)
+}
+
+// Submit runs the job in process with the input and output paths, Files,
+// CacheFiles, Properties and ReducerTasks of j
+func (b *FakeBackend) Submit(ctx context.Context, j hdfs.Job, step gomrjob.Step) error {
+	b.mu.Lock()
+	b.jobs = append(b.jobs, j)
+	jobID := fmt.Sprintf("job_fake_%04d", len(b.jobs))
+	b.mu.Unlock()
+
+	if b.SubmitError != nil {
+		if err := b.SubmitError(j); err != nil {
+			return err
+		}
+	}
+	if j.Mapper == "" || j.Reducer == "" {
+		return errors.New("missing argument Mapper or Reducer")
+	}
+	output := hdfs.AbsolutePath(j.Output, j.DefaultProto)
+	if _, err := mrfs.Stat(ctx, output); err == nil {
+		return fmt.Errorf("output directory %s already exists", output)
+	}
+
+	var splits []inputSplit
+	for _, pattern := range j.Input {
+		s, err := readSplits(ctx, hdfs.AbsolutePath(pattern, j.DefaultProto))
+		if err != nil {
+			return err
+		}
+		if len(s) == 0 {
+			return fmt.Errorf("input path does not exist: %s", pattern)
+		}
+		splits = append(splits, s...)
+	}
+
+	dir, err := workingDir(b.t, j.CacheFiles, j.Files)
+	if err != nil {
+		return err
+	}
+	o := Options{Reducers: j.ReducerTasks, Combine: j.Combiner != "", MapOnly: j.ReducerTasks == 0, Seed: 1}
+	if b.TaskError != nil {
+		o.taskError = func(stage string) error { return b.TaskError(j, stage) }
+	}
+	var result *StepResult
+	err = inTaskEnv(dir, j.Properties, func() error {
+		var err error
+		result, err = runStep(b.t, step, splits, o)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", jobID, err)
+	}
+
+	if err := writeOutput(output, result.Parts, j.Properties["mapred.output.compress"] == "true"); err != nil {
+		return err
+	}
+	if j.OnProgress != nil {
+		j.OnProgress(hdfs.Progress{JobID: jobID, State: "SUCCEEDED", Map: 100, Reduce: 100})
+	}
+	if j.OnCounters != nil {
+		j.OnCounters(result.Counters)
+	}
+	return nil
+}
+
+// readSplits reads each file matching pattern (expanding directories) as
+// hadoop would, skipping files starting with "_" or "."
+func readSplits(ctx context.Context, pattern string) ([]inputSplit, error) {
+	matches, err := mrfs.Glob(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
+	var files []*mrfs.FileInfo
+	for _, f := range matches {
+		if !f.IsDir() {
+			files = append(files, f)
+			continue
+		}
+		contents, err := mrfs.Glob(ctx, strings.TrimSuffix(f.Path(), "/")+"/*")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, contents...)
+	}
+	var splits []inputSplit
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), "_") || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		lines, err := readFile(ctx, f.Path())
+		if err != nil {
+			return nil, err
+		}
+		splits = append(splits, inputSplit{file: f.Path(), lines: lines})
+	}
+	return splits, nil
+}
+
+func readFile(ctx context.Context, name string) ([][]byte, error) {
+	rc, err := mrfs.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var r io.Reader = rc
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+		}
+		r = gz
+	}
+	return readLines(r)
+}
+
+// taskEnvMu serializes jobs run by FakeBackends, which set the environment and
+// working directory of the process as hadoop does for a task. Other code
+// running at the same time is not serialized.
+var taskEnvMu sync.Mutex
+
+// inTaskEnv runs f in dir with properties set in the environment, restoring
+// the previous environment and working directory when f returns
+func inTaskEnv(dir string, properties map[string]string, f func() error) error {
+	taskEnvMu.Lock()
+	defer taskEnvMu.Unlock()
+	env := map[string]string{"map_input_file": "", "mapreduce_map_input_file": ""}
+	for k, v := range properties {
+		env[strings.ReplaceAll(k, ".", "_")] = v
+	}
+	for k, v := range env {
+		prev, ok := os.LookupEnv(k)
+		os.Setenv(k, v)
+		if ok {
+			defer os.Setenv(k, prev)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	defer os.Chdir(wd)
+	return f()
+}
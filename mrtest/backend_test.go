@@ -0,0 +1,96 @@
+package mrtest
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/jehiah/gomrjob/dataproc"
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/jehiah/gomrjob/mrproto"
+	"github.com/stretchr/testify/assert"
+)
+
+// sumStep sums values by key with the identity mapper
+type sumStep struct{}
+
+func (sumStep) Reducer(r io.Reader, w io.Writer) error { return mrproto.Sum(r, w) }
+
+func newFakeRunner(t *testing.T) (*gomrjob.Runner, *FakeBackend) {
+	backend := NewFakeBackend(t)
+	dir := filepath.Join(backend.Dir, "input")
+	assert.Equal(t, os.MkdirAll(dir, 0755), nil)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "a"), []byte("a b\nc"), 0644), nil)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "_SUCCESS"), nil, 0644), nil)
+	f, err := os.Create(filepath.Join(dir, "b.gz"))
+	assert.Equal(t, err, nil)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("a\nb\n"))
+	gz.Close()
+	f.Close()
+
+	r := gomrjob.NewRunner()
+	r.Name = "fake"
+	r.Steps = []gomrjob.Step{&wordCount{}, sumStep{}}
+	r.InputFiles = []string{"input"}
+	r.ReducerTasks = 2
+	r.Progress = gomrjob.ProgressFunc(func(gomrjob.StepProgress) {})
+	r.Backend = backend
+	return r, backend
+}
+
+func TestFakeBackend(t *testing.T) {
+	r, backend := newFakeRunner(t)
+	r.Steps[0] = &countedStep{}
+	var events []gomrjob.StepEvent
+	r.Hooks.AfterStep = func(e gomrjob.StepEvent) { events = append(events, e) }
+	assert.Equal(t, r.Run(), nil)
+
+	var lines []string
+	for line, err := range r.OutputLines() {
+		assert.Equal(t, err, nil)
+		lines = append(lines, string(line))
+	}
+	assert.Equal(t, sortedLines([]byte(strings.Join(lines, "\n"))), []string{"a\t2", "b\t2", "c\t1"})
+
+	jobs := backend.Jobs()
+	assert.Equal(t, len(jobs), 2)
+	assert.Equal(t, jobs[0].Input, []string{"input"})
+	assert.True(t, strings.HasSuffix(jobs[1].Input[0], "/step_0/output/part-*"), jobs[1].Input)
+	assert.Equal(t, len(backend.Staged()), 1)
+	assert.True(t, strings.HasSuffix(backend.Staged()[0], "/gomrjob_binary"))
+
+	assert.Equal(t, len(events), 2)
+	assert.Equal(t, events[0].Progress.JobID, "job_fake_0001")
+	assert.Equal(t, events[0].Counters.Get("counted", "mappers"), int64(2))
+	assert.Equal(t, events[0].Counters.Get("counted", "reducers"), int64(2))
+}
+
+func TestFakeBackendFailures(t *testing.T) {
+	r, backend := newFakeRunner(t)
+	backend.SubmitError = FailFirst(1, dataproc.ErrUnavailable)
+	err := r.Run()
+	assert.True(t, errors.Is(err, dataproc.ErrUnavailable), err)
+	assert.Equal(t, r.Run(), nil)
+	jobs := backend.Jobs()
+	assert.Equal(t, len(jobs), 3)
+	// the binary staged by each Run is not added to the Runner
+	assert.Equal(t, len(jobs[2].CacheFiles), 1)
+	assert.Equal(t, len(r.CacheFiles), 0)
+
+	r, backend = newFakeRunner(t)
+	backend.TaskError = func(j hdfs.Job, stage string) error {
+		if stage == "reducer" && j.Name == "fake-step_1" {
+			return errors.New("task failed")
+		}
+		return nil
+	}
+	err = r.Run()
+	assert.NotEqual(t, err, nil)
+	assert.Contains(t, err.Error(), "Step 1 = job_fake_0002 failed: reduce failed with task failed")
+}
@@ -0,0 +1,73 @@
+package mrtest
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+)
+
+// Benchmark benchmarks the mapper, combiner and reducer of a step over in as
+// sub-benchmarks. Each reports bytes/sec (MB/s), records/s and allocations
+// per record for its phase. The combiner and reducer are run over the sorted
+// output of the mapper. Counters are batched as they are in a hadoop task, and
+// they and any status reported by the step are discarded.
+func Benchmark(b *testing.B, s gomrjob.Step, in io.Reader) {
+	lines, err := readLines(in)
+	if err != nil {
+		b.Fatalf("failed reading input %s", err)
+	}
+
+	mapOut := lines
+	if m, ok := s.(gomrjob.Mapper); ok {
+		b.Run("mapper", func(b *testing.B) { benchmarkPhase(b, m.Mapper, lines) })
+		flush := gomrjob.CaptureReporter(io.Discard)
+		mapOut, err = runPhase(m.Mapper, lines)
+		flush()
+		if err != nil {
+			b.Fatalf("mapper failed with %s", err)
+		}
+	}
+	sortByKey(mapOut)
+	if c, ok := s.(gomrjob.Combiner); ok {
+		b.Run("combiner", func(b *testing.B) { benchmarkPhase(b, c.Combiner, mapOut) })
+	}
+	b.Run("reducer", func(b *testing.B) { benchmarkPhase(b, s.Reducer, mapOut) })
+}
+
+// BenchmarkFile runs Benchmark with the contents of a file (which is
+// decompressed if name+".gz" exists instead)
+func BenchmarkFile(b *testing.B, s gomrjob.Step, name string) {
+	in, err := openInput(name)
+	if err != nil {
+		b.Fatalf("failed reading input %s", err)
+	}
+	Benchmark(b, s, in)
+}
+
+func benchmarkPhase(b *testing.B, f func(io.Reader, io.Writer) error, lines [][]byte) {
+	// counters are batched as they are when a task is run by hadoop
+	flush := gomrjob.CaptureReporter(io.Discard)
+	defer flush()
+	input := bytes.Join(lines, nil)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := f(bytes.NewReader(input), io.Discard); err != nil {
+			b.Fatalf("failed with %s", err)
+		}
+	}
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+	records := float64(len(lines)) * float64(b.N)
+	if records == 0 {
+		return
+	}
+	b.ReportMetric(records/b.Elapsed().Seconds(), "records/s")
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/records, "allocs/record")
+}
@@ -0,0 +1,45 @@
+package mrtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+)
+
+func BenchmarkWordCount(b *testing.B) {
+	var in strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&in, "word%d word%d common\n", i%50, i%7)
+	}
+	Benchmark(b, &wordCount{}, strings.NewReader(in.String()))
+}
+
+func BenchmarkWordCountFile(b *testing.B) {
+	BenchmarkFile(b, &wordCount{}, "testdata/wordcount.input")
+}
+
+// countingStep counts each record with a CounterHandle
+type countingStep struct{ wordCount }
+
+var benchmarkLines = gomrjob.NewCounter("benchmark", "lines")
+
+func (c *countingStep) Mapper(r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		benchmarkLines.Inc()
+		fmt.Fprintf(w, "%s\t1\n", s.Text())
+	}
+	return s.Err()
+}
+
+func BenchmarkCounter(b *testing.B) {
+	var in strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&in, "line%d\n", i%50)
+	}
+	Benchmark(b, &countingStep{}, strings.NewReader(in.String()))
+}
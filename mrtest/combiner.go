@@ -0,0 +1,104 @@
+package mrtest
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jehiah/gomrjob"
+)
+
+// CombinerCheck controls the trials run by CheckCombiner
+type CombinerCheck struct {
+	Trials int // defaults to 100
+
+	// Seed seeds the first trial; each later trial uses the next seed. When 0
+	// a seed is chosen. A failure reports the seed that reproduces it.
+	Seed int64
+}
+
+// CheckCombiner checks that the output of a step is the same whether or not
+// its Combiner runs. Hadoop may run a combiner any number of times, on any
+// subset of a mapper's output and on its own output, so a Combiner must be
+// associative and produce records the reducer (and the combiner) can consume.
+//
+// Each trial shuffles the mapper output of in, splits it into random subsets
+// and combines each subset one or more times, sometimes combining the merged
+// results again, before running the reducer. The first difference from the
+// reducer output without the combiner is reported with t.Errorf.
+func CheckCombiner(t testing.TB, s gomrjob.Step, in io.Reader, o CombinerCheck) bool {
+	c, ok := s.(gomrjob.Combiner)
+	if !ok {
+		t.Errorf("step %T does not implement Combiner", s)
+		return false
+	}
+	lines, err := readLines(in)
+	if err != nil {
+		t.Errorf("failed reading input %s", err)
+		return false
+	}
+	if m, ok := s.(gomrjob.Mapper); ok {
+		if lines, err = runPhase(m.Mapper, lines); err != nil {
+			t.Errorf("mapper failed with %s", err)
+			return false
+		}
+	}
+	expected, err := reduce(s, append([][]byte(nil), lines...))
+	if err != nil {
+		t.Errorf("reduce failed with %s", err)
+		return false
+	}
+
+	trials, seed := o.Trials, o.Seed
+	if trials <= 0 {
+		trials = 100
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	for i := 0; i < trials; i++ {
+		rng := rand.New(rand.NewSource(seed + int64(i)))
+		combined, err := combineSubsets(rng, c, lines)
+		if err != nil {
+			t.Errorf("combiner failed with %s (CombinerCheck{Seed: %d, Trials: 1})", err, seed+int64(i))
+			return false
+		}
+		result, err := reduce(s, combined)
+		if err != nil {
+			t.Errorf("reduce of combined output failed with %s (CombinerCheck{Seed: %d, Trials: 1})", err, seed+int64(i))
+			return false
+		}
+		if !bytes.Equal(result, expected) {
+			t.Logf("reducer input after combining:\n%s", bytes.Join(combined, nil))
+			t.Logf("got output:\n%s", result)
+			t.Logf("expected output (without combiner):\n%s", expected)
+			t.Errorf("output with combiner does not match output without it (CombinerCheck{Seed: %d, Trials: 1})", seed+int64(i))
+			return false
+		}
+	}
+	return true
+}
+
+// combineSubsets shuffles lines into random subsets and runs the combiner one
+// or more times on each (see combineChunks)
+func combineSubsets(rng *rand.Rand, c gomrjob.Combiner, lines [][]byte) ([][]byte, error) {
+	shuffled := append([][]byte(nil), lines...)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	subsets := make([][][]byte, 1+rng.Intn(max(min(len(shuffled), 5), 1)))
+	for _, line := range shuffled {
+		i := rng.Intn(len(subsets))
+		subsets[i] = append(subsets[i], line)
+	}
+	return combineChunks(rng, c.Combiner, subsets, 1)
+}
+
+func reduce(s gomrjob.Step, lines [][]byte) ([]byte, error) {
+	sortByKey(lines)
+	out, err := runPhase(s.Reducer, lines)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(bytes.Join(out, nil)), nil
+}
@@ -0,0 +1,57 @@
+package mrtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingT records failures instead of failing the test
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+func (r *recordingT) Logf(string, ...any) {}
+
+// countLines counts records per key, but its combiner also counts records so
+// combined counts are counted as 1
+type countLines struct{ wordCount }
+
+func (c *countLines) Combiner(r io.Reader, w io.Writer) error { return c.Reducer(r, w) }
+func (c *countLines) Reducer(r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+	var last string
+	var n int
+	for s.Scan() {
+		k, _, _ := strings.Cut(s.Text(), "\t")
+		if k != last && n > 0 {
+			fmt.Fprintf(w, "%s\t%d\n", last, n)
+			n = 0
+		}
+		last = k
+		n++
+	}
+	if n > 0 {
+		fmt.Fprintf(w, "%s\t%d\n", last, n)
+	}
+	return s.Err()
+}
+
+func TestCheckCombiner(t *testing.T) {
+	in := "a b c\nb c\nc d e f\na\ng h\nc c c"
+	assert.True(t, CheckCombiner(t, &wordCount{}, strings.NewReader(in), CombinerCheck{}))
+
+	rt := recordingT{TB: t}
+	ok := CheckCombiner(&rt, &countLines{}, strings.NewReader(in), CombinerCheck{Seed: 1})
+	assert.False(t, ok)
+	assert.Equal(t, len(rt.errors), 1)
+	assert.Contains(t, rt.errors[0], "does not match output without it (CombinerCheck{Seed: ")
+}
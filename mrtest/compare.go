@@ -0,0 +1,160 @@
+package mrtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Diff returns a unified diff of the lines of expected and got after applying
+// the comparison settings of o (Unordered, JSON). It returns "" when they match.
+func Diff(expected, got []byte, o Options) string {
+	a, b := normalize(expected, o), normalize(got, o)
+	if slices.Equal(a, b) {
+		return ""
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        a,
+		B:        b,
+		FromFile: "expected",
+		ToFile:   "got",
+		Context:  2,
+	})
+	return diff
+}
+
+// normalize splits output into newline terminated lines for comparison
+func normalize(b []byte, o Options) []string {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.Split(string(b), "\n")
+	for i, line := range lines {
+		if o.JSON {
+			if k, v, ok := strings.Cut(line, "\t"); ok {
+				line = canonicalJSON(k) + "\t" + canonicalJSON(v)
+			} else {
+				line = canonicalJSON(line)
+			}
+		}
+		lines[i] = line + "\n"
+	}
+	if o.Unordered {
+		sort.Strings(lines)
+	}
+	return lines
+}
+
+// canonicalJSON re-encodes s with sorted object keys and numbers formatted
+// consistently, or returns s unchanged if it is not JSON. Integers too large
+// to be represented exactly as a float64 (i.e. IDs) are compared exactly.
+func canonicalJSON(s string) string {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return s
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return s
+	}
+	b, err := json.Marshal(canonicalNumbers(v))
+	if err != nil {
+		return s
+	}
+	return string(b)
+}
+
+// canonicalNumbers replaces each json.Number in v with a float64 unless it is
+// an integer with more than 53 bits
+func canonicalNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok && i.BitLen() > 53 {
+			return json.Number(i.String())
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	case map[string]any:
+		for k, e := range v {
+			v[k] = canonicalNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = canonicalNumbers(e)
+		}
+	}
+	return v
+}
+
+// diffKeys returns the keys of lines added or removed in a unified diff
+func diffKeys(diff string) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++") {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "+") {
+			continue
+		}
+		k := string(key([]byte(line[1:])))
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func compareOutput(t testing.TB, result []byte, out io.Reader, o Options) bool {
+	outBytes, err := io.ReadAll(out)
+	if err != nil {
+		t.Errorf("failed reading expected output %s", err)
+		return false
+	}
+	return compareBytes(t, "output", result, outBytes, o)
+}
+
+func compareBytes(t testing.TB, name string, result, expected []byte, o Options) bool {
+	diff := Diff(expected, result, o)
+	if diff == "" {
+		return true
+	}
+	t.Logf("%s differs:\n%s", name, diff)
+	t.Errorf("%s does not match expected output for keys %q", name, diffKeys(diff))
+	return false
+}
+
+// TestMapReduceStepSnapshots runs steps in order and compares the output of
+// each step with the corresponding snapshot. A nil snapshot is not compared.
+// It returns the output of each step.
+func TestMapReduceStepSnapshots(t testing.TB, ss []gomrjob.Step, in io.Reader, snapshots []io.Reader, o Options) [][]byte {
+	var outputs [][]byte
+	for i, s := range ss {
+		result := RunStep(t, s, in, o)
+		outputs = append(outputs, result)
+		in = bytes.NewBuffer(result)
+		if i >= len(snapshots) || snapshots[i] == nil {
+			continue
+		}
+		expected, err := io.ReadAll(snapshots[i])
+		if err != nil {
+			t.Errorf("failed reading snapshot for step %d %s", i, err)
+			continue
+		}
+		compareBytes(t, fmt.Sprintf("step %d output", i), result, expected, o)
+	}
+	return outputs
+}
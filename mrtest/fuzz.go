@@ -0,0 +1,206 @@
+package mrtest
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+)
+
+// Properties are the properties CheckStep verifies
+type Properties struct {
+	// KeyValueOutput requires each reducer output line to be "key\tvalue" as
+	// it is when the output is read by a later step. Mapper and combiner
+	// output is always checked.
+	KeyValueOutput bool
+
+	AllowErrors    bool // don't report errors returned by the step as failures
+	OrderSensitive bool // don't check that reordering input lines gives the same output
+}
+
+// longLine is longer than the 2MB buffer used by mrproto readers
+var longLine = strings.Repeat("x", 2*1024*1024+1)
+
+// FuzzSeeds returns inputs that exercise the edge cases a step sees on a
+// cluster: empty lines, lines without a tab, malformed JSON, invalid UTF-8,
+// \r\n line endings, missing trailing newlines and lines longer than the
+// buffer used by mrproto readers. Each valid line is also included with
+// these variations.
+func FuzzSeeds(valid ...string) [][]byte {
+	seeds := []string{
+		"",
+		"\n",
+		"\n\n\n",
+		"no tab",
+		"\t",
+		"key\t",
+		"\tvalue",
+		"a\tb\tc",
+		`{"a":`,
+		`{"a":1}`,
+		`"key"` + "\t" + `{"a":`,
+		`["a",1]` + "\t" + `{"a":1}`,
+		"\xff\xfe\t\x00",
+		"a\tb\r\n",
+		longLine,
+		`"` + longLine + `"` + "\t1",
+		`{"a":"` + longLine + `"}`,
+	}
+	for _, v := range valid {
+		seeds = append(seeds,
+			v,
+			v+"\n",
+			v+"\n\n"+v,
+			strings.ReplaceAll(v, "\n", "\r\n"),
+			v+"\n"+`{"a":`+"\n"+v,
+			v+"\nno tab\n"+v+"\n",
+		)
+	}
+	var o [][]byte
+	for _, s := range seeds {
+		o = append(o, []byte(s))
+	}
+	return o
+}
+
+// FuzzStep adds FuzzSeeds to f and checks s with CheckStep for each input
+//
+//	func FuzzMyStep(f *testing.F) {
+//		mrtest.FuzzStep(f, &MyStep{}, mrtest.Properties{}, `{"valid":"record"}`)
+//	}
+func FuzzStep(f *testing.F, s gomrjob.Step, p Properties, valid ...string) {
+	for _, seed := range FuzzSeeds(valid...) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		CheckStep(t, s, in, p)
+	})
+}
+
+// CheckStep runs s over in (mapper, sort, reducer) and checks that it does
+// not panic or return an error, that mapper and combiner output lines are
+// framed as "key\tvalue", and that the output doesn't depend on the order of
+// the input lines. The step is run several times. Panics are only recovered
+// in the goroutine running a stage, not in goroutines it starts.
+func CheckStep(t testing.TB, s gomrjob.Step, in []byte, p Properties) bool {
+	t.Helper()
+	c := &checker{t: t, s: s, p: p}
+	var checked gomrjob.Step = c
+	if _, ok := s.(gomrjob.Combiner); ok {
+		checked = &checkedCombiner{c}
+	}
+
+	lines, err := readLines(bytes.NewReader(in))
+	if err != nil {
+		t.Errorf("failed reading input %s", err)
+		return false
+	}
+	o := Options{Combine: true, Seed: inputSeed(in)}
+	expected, err := runStep(t, checked, []inputSplit{{lines: lines}}, o)
+	if !c.ok(err) {
+		return false
+	}
+	if p.OrderSensitive || len(lines) < 2 {
+		return true
+	}
+
+	reversed := append([][]byte(nil), lines...)
+	slices.Reverse(reversed)
+	shuffled := append([][]byte(nil), lines...)
+	rng := rand.New(rand.NewSource(o.Seed))
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	for _, reordered := range [][][]byte{reversed, shuffled} {
+		splits := []inputSplit{{lines: reordered[:len(reordered)/2]}, {lines: reordered[len(reordered)/2:]}}
+		result, err := runStep(t, checked, splits, o)
+		if !c.ok(err) {
+			return false
+		}
+		if diff := Diff(expected.Output, result.Output, Options{Unordered: true}); diff != "" {
+			t.Logf("output differs with input lines reordered:\n%s", diff)
+			t.Errorf("output depends on the order of input lines for keys %q", diffKeys(diff))
+			return false
+		}
+	}
+	return true
+}
+
+// inputSeed derives a seed from the input so that failures are reproducible
+func inputSeed(in []byte) int64 {
+	h := fnv.New64a()
+	h.Write(in)
+	return int64(h.Sum64()>>1) + 1
+}
+
+// checker wraps each stage of a step to check its output and recover panics
+type checker struct {
+	t      testing.TB
+	s      gomrjob.Step
+	p      Properties
+	failed bool
+}
+
+// ok reports an error from running the step unless the failure was already reported
+func (c *checker) ok(err error) bool {
+	if err != nil && !c.failed {
+		c.t.Errorf("%s", err)
+	}
+	return err == nil && !c.failed
+}
+
+type checkedCombiner struct{ *checker }
+
+func (c *checkedCombiner) Combiner(r io.Reader, w io.Writer) error {
+	return c.run("combiner", c.s.(gomrjob.Combiner).Combiner, r, w, true)
+}
+
+func (c *checker) Mapper(r io.Reader, w io.Writer) error {
+	m, ok := c.s.(gomrjob.Mapper)
+	if !ok {
+		_, err := io.Copy(w, r)
+		return err
+	}
+	return c.run("mapper", m.Mapper, r, w, true)
+}
+
+func (c *checker) Reducer(r io.Reader, w io.Writer) error {
+	return c.run("reducer", c.s.Reducer, r, w, c.p.KeyValueOutput)
+}
+
+func (c *checker) run(stage string, f func(io.Reader, io.Writer) error, r io.Reader, w io.Writer, keyValue bool) (err error) {
+	var out bytes.Buffer
+	defer func() {
+		if p := recover(); p != nil {
+			c.failed = true
+			c.t.Errorf("%s panic: %v\n%s", stage, p, debug.Stack())
+			err = fmt.Errorf("%s panic", stage)
+		}
+	}()
+	if err := f(r, &out); err != nil {
+		if c.p.AllowErrors {
+			return nil
+		}
+		c.failed = true
+		return err
+	}
+	if keyValue {
+		for i, line := range bytes.SplitAfter(out.Bytes(), []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			if !bytes.Contains(line, []byte("\t")) {
+				c.failed = true
+				c.t.Errorf("%s output line %d is not key\\tvalue: %.100q", stage, i+1, line)
+				return fmt.Errorf("%s output not framed", stage)
+			}
+		}
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
@@ -0,0 +1,71 @@
+package mrtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzWordCount(f *testing.F) {
+	FuzzStep(f, &wordCount{}, Properties{KeyValueOutput: true}, "a b c\nb c")
+}
+
+// badStep panics on "panic", writes unframed lines for "unframed" and
+// reports only the first value for each key
+type badStep struct{}
+
+func (badStep) Mapper(r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		switch s.Text() {
+		case "panic":
+			panic("bad input")
+		case "unframed":
+			fmt.Fprintln(w, "unframed")
+		default:
+			fmt.Fprintf(w, "k\t%s\n", s.Text())
+		}
+	}
+	return nil
+}
+
+func (badStep) Reducer(r io.Reader, w io.Writer) error {
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	_, err := io.WriteString(w, line)
+	return err
+}
+
+func TestCheckStep(t *testing.T) {
+	tests := []struct {
+		in    string
+		error string
+	}{
+		{"a", ""},
+		{"panic", "mapper panic: bad input"},
+		{"unframed", `mapper output line 1 is not key\tvalue: "unframed\n"`},
+		{"a\nb\nc\nd\ne\nf", "output depends on the order of input lines"},
+	}
+	for _, tc := range tests {
+		rt := recordingT{TB: t}
+		ok := CheckStep(&rt, badStep{}, []byte(tc.in), Properties{})
+		assert.Equal(t, ok, tc.error == "", tc.in)
+		if tc.error != "" {
+			assert.NotEqual(t, len(rt.errors), 0, tc.in)
+			assert.True(t, len(rt.errors) > 0 && strings.HasPrefix(rt.errors[0], tc.error), "%q %v", tc.in, rt.errors)
+		}
+	}
+}
+
+func TestFuzzSeeds(t *testing.T) {
+	seeds := FuzzSeeds("a")
+	var long bool
+	for _, s := range seeds {
+		long = long || len(s) > 2*1024*1024
+	}
+	assert.True(t, long)
+	assert.Contains(t, seeds, []byte("a\n\na"))
+}
@@ -0,0 +1,80 @@
+package mrtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+)
+
+var update = flag.Bool("mrtest.update", false, "rewrite mrtest golden files with the current output")
+
+// TestGolden runs a step over testdata/<name>.input (or testdata/<name>.input.gz)
+// and compares the output with testdata/<name>.golden. When tests are run with
+// -mrtest.update the golden file is written instead.
+func TestGolden(t testing.TB, name string, s gomrjob.Step, o Options) []byte {
+	return TestGoldenSteps(t, name, []gomrjob.Step{s}, o)
+}
+
+// TestGoldenSteps is TestGolden for a pipeline of steps
+func TestGoldenSteps(t testing.TB, name string, ss []gomrjob.Step, o Options) []byte {
+	return golden(t, "testdata", name, ss, o)
+}
+
+func golden(t testing.TB, dir, name string, ss []gomrjob.Step, o Options) []byte {
+	in, err := openInput(filepath.Join(dir, name+".input"))
+	if err != nil {
+		t.Errorf("failed reading input %s", err)
+		return nil
+	}
+	result := RunSteps(t, ss, in, o)
+
+	goldenFile := filepath.Join(dir, name+".golden")
+	if *update {
+		if err := os.WriteFile(goldenFile, append(result, '\n'), 0644); err != nil {
+			t.Errorf("failed updating golden file %s", err)
+		}
+		return result
+	}
+	expected, err := os.ReadFile(goldenFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing %s; run tests with -mrtest.update to create it", goldenFile)
+		return result
+	}
+	if err != nil {
+		t.Errorf("failed reading golden file %s", err)
+		return result
+	}
+	compareBytes(t, goldenFile, result, expected, o)
+	return result
+}
+
+// openInput reads name, or name.gz decompressed
+func openInput(name string) (io.Reader, error) {
+	b, err := os.ReadFile(name)
+	if err == nil {
+		return bytes.NewReader(b), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	b, gzErr := os.ReadFile(name + ".gz")
+	if errors.Is(gzErr, fs.ErrNotExist) {
+		return nil, err
+	}
+	if gzErr != nil {
+		return nil, gzErr
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return gz, nil
+}
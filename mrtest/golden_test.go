@@ -0,0 +1,40 @@
+package mrtest
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoldenWordCount(t *testing.T) {
+	TestGolden(t, "wordcount", &wordCount{}, Options{Reducers: 2, Combine: true, Unordered: true})
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "gz.input.gz"))
+	assert.Equal(t, err, nil)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("a b\nb\n"))
+	gz.Close()
+	f.Close()
+
+	rt := recordingT{TB: t}
+	golden(&rt, dir, "gz", []gomrjob.Step{&wordCount{}}, Options{})
+	assert.Equal(t, len(rt.errors), 1)
+	assert.Contains(t, rt.errors[0], "run tests with -mrtest.update")
+
+	*update = true
+	defer func() { *update = false }()
+	golden(t, dir, "gz", []gomrjob.Step{&wordCount{}}, Options{})
+	b, err := os.ReadFile(filepath.Join(dir, "gz.golden"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(b), "a\t1\nb\t2\n")
+
+	*update = false
+	golden(t, dir, "gz", []gomrjob.Step{&wordCount{}}, Options{})
+}
@@ -0,0 +1,145 @@
+package mrtest
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+// report collects the counters and status messages reported while running a step
+type report struct {
+	mu     sync.Mutex
+	stages map[string]hdfs.Counters
+	status []string
+	before func(stage string) error
+}
+
+func newReport() *report {
+	return &report{stages: make(map[string]hdfs.Counters)}
+}
+
+// capture wraps a map, combine or reduce function so that counters and status
+// reported while it runs are collected for stage. Phases of steps run by
+// parallel tests are run one at a time (see gomrjob.CaptureReporter).
+func (r *report) capture(stage string, f func(io.Reader, io.Writer) error) func(io.Reader, io.Writer) error {
+	return func(in io.Reader, out io.Writer) error {
+		if r.before != nil {
+			if err := r.before(stage); err != nil {
+				return err
+			}
+		}
+		var b bytes.Buffer
+		flush := gomrjob.CaptureReporter(&b)
+		err := f(in, out)
+		flush()
+		r.parse(stage, &b)
+		return err
+	}
+}
+
+// parse totals reporter:counter lines and records reporter:status lines
+func (r *report) parse(stage string, in io.Reader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := bufio.NewScanner(in)
+	for s.Scan() {
+		if status, ok := strings.CutPrefix(s.Text(), "reporter:status:"); ok {
+			r.status = append(r.status, status)
+			continue
+		}
+		line, ok := strings.CutPrefix(s.Text(), "reporter:counter:")
+		if !ok {
+			continue
+		}
+		group, rest, ok := strings.Cut(line, ",")
+		i := strings.LastIndex(rest, ",")
+		if !ok || i == -1 {
+			continue
+		}
+		n, err := strconv.ParseInt(rest[i+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		addCounter(r.stages, stage, group, rest[:i], n)
+	}
+}
+
+func addCounter(stages map[string]hdfs.Counters, stage, group, name string, n int64) {
+	if stages[stage] == nil {
+		stages[stage] = make(hdfs.Counters)
+	}
+	addCounters(stages[stage], hdfs.Counters{group: {name: n}})
+}
+
+// addCounters adds the counters in src to dst
+func addCounters(dst, src hdfs.Counters) {
+	for group, names := range src {
+		if dst[group] == nil {
+			dst[group] = make(map[string]int64)
+		}
+		for name, n := range names {
+			dst[group][name] += n
+		}
+	}
+}
+
+// totals returns the counters summed across stages
+func (r *report) totals() hdfs.Counters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o := make(hdfs.Counters)
+	for _, c := range r.stages {
+		addCounters(o, c)
+	}
+	return o
+}
+
+// reports are the counters collected for each test
+var reports = struct {
+	sync.Mutex
+	m map[testing.TB]hdfs.Counters
+}{m: make(map[testing.TB]hdfs.Counters)}
+
+// recordReport adds the counters of a step run by t to those checked by AssertCounter
+func recordReport(t testing.TB, r *report) {
+	totals := r.totals()
+	reports.Lock()
+	defer reports.Unlock()
+	c, ok := reports.m[t]
+	if !ok {
+		c = make(hdfs.Counters)
+		reports.m[t] = c
+		t.Cleanup(func() {
+			reports.Lock()
+			delete(reports.m, t)
+			reports.Unlock()
+		})
+	}
+	addCounters(c, totals)
+}
+
+// Counters returns the total of the counters reported by steps run by t
+func Counters(t testing.TB) hdfs.Counters {
+	reports.Lock()
+	defer reports.Unlock()
+	o := make(hdfs.Counters)
+	addCounters(o, reports.m[t])
+	return o
+}
+
+// AssertCounter checks the total of a counter reported by the steps run by t
+func AssertCounter(t testing.TB, group, name string, want int64) bool {
+	t.Helper()
+	if got := Counters(t).Get(group, name); got != want {
+		t.Errorf("counter %s,%s got %d expected %d", group, name, got, want)
+		return false
+	}
+	return true
+}
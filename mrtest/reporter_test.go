@@ -0,0 +1,63 @@
+package mrtest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/jehiah/gomrjob/mrproto"
+	"github.com/stretchr/testify/assert"
+)
+
+// countedStep reports counters from each stage
+type countedStep struct{ wordCount }
+
+func (c *countedStep) Mapper(r io.Reader, w io.Writer) error {
+	gomrjob.Counter("counted", "mappers", 1)
+	return c.wordCount.Mapper(r, w)
+}
+
+func (c *countedStep) Combiner(r io.Reader, w io.Writer) error {
+	gomrjob.Counter("counted", "combiners", 1)
+	return mrproto.Sum(r, w)
+}
+
+func (c *countedStep) Reducer(r io.Reader, w io.Writer) error {
+	gomrjob.NewCounter("counted", "reducers").Inc()
+	gomrjob.Status("reducing")
+	return mrproto.Sum(r, w)
+}
+
+func TestCaptureCounters(t *testing.T) {
+	in := "a b\nc d\ne f"
+	result := RunStepResult(t, &countedStep{}, strings.NewReader(in), Options{Mappers: 3, Reducers: 2})
+	assert.Equal(t, result.StageCounters["mapper"].Get("counted", "mappers"), int64(3))
+	assert.Equal(t, result.StageCounters["reducer"].Get("counted", "reducers"), int64(2))
+	assert.Equal(t, result.Counters.Get("counted", "mappers"), int64(3))
+	assert.Equal(t, result.Status, []string{"reducing", "reducing"})
+	// RecordsIn counters are only reported when a task exits
+	assert.Equal(t, len(result.Counters), 1)
+
+	RunStep(t, &countedStep{}, strings.NewReader(in), Options{})
+	AssertCounter(t, "counted", "mappers", 4)
+	AssertCounter(t, "counted", "reducers", 3)
+
+	rt := recordingT{TB: t}
+	assert.False(t, AssertCounter(&rt, "counted", "mappers", 1))
+	assert.Equal(t, rt.errors, []string{"counter counted,mappers got 0 expected 1"})
+}
+
+func TestCaptureCountersParallel(t *testing.T) {
+	for i := 1; i <= 4; i++ {
+		n := i
+		t.Run(fmt.Sprintf("mappers=%d", n), func(t *testing.T) {
+			t.Parallel()
+			for j := 0; j < 20; j++ {
+				result := RunStepResult(t, &countedStep{}, strings.NewReader("a\nb\nc\nd"), Options{Mappers: n})
+				assert.Equal(t, result.Counters.Get("counted", "mappers"), int64(n))
+			}
+		})
+	}
+}
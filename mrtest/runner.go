@@ -0,0 +1,240 @@
+package mrtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/jehiah/gomrjob/mrfs"
+)
+
+// RunnerResult is the result of RunRunner
+type RunnerResult struct {
+	Steps []StepResult
+}
+
+// Output is the output of the final step
+func (r *RunnerResult) Output() []byte {
+	if len(r.Steps) == 0 {
+		return nil
+	}
+	return r.Steps[len(r.Steps)-1].Output
+}
+
+// StepResult is the output of one step
+type StepResult struct {
+	Output        []byte                   // the output of each reducer concatenated
+	Parts         [][]byte                 // the output of each reducer
+	Counters      hdfs.Counters            // counters reported by the step's tasks
+	StageCounters map[string]hdfs.Counters // counters by stage ("mapper", "combiner", "reducer")
+	Status        []string                 // status messages in the order reported
+}
+
+// RunRunner runs the steps of r in process as Run would on a cluster. inputs
+// maps an input file name to its contents; each file is read by a separate
+// mapper with map_input_file set to its name.
+//
+// The Runner settings tasks would observe are honored:
+//   - the number of reducers from StepReducerTasksCount or ReducerTasks; with 0 the step is map only
+//   - the Combiner of steps that implement one is run
+//   - PassThroughOptions are set on the flags of the test binary
+//   - Properties are set as environment variables (with "." replaced by "_")
+//   - CacheFiles and Files are copied to a temporary working directory, using the name after "#" when present
+//   - the output of the final step is written as part-NNNNN files to Output when set (i.e. "file:///tmp/output"),
+//     compressed when CompressOutput is set
+//
+// Properties are set with t.Setenv, so RunRunner panics when called from a test
+// that uses t.Parallel, and the working directory of the process is changed
+// while the steps run, which races with parallel tests that use relative paths.
+func RunRunner(t testing.TB, r *gomrjob.Runner, inputs map[string]io.Reader) *RunnerResult {
+	result := &RunnerResult{}
+	if !setPassThroughOptions(t, r.PassThroughOptions) {
+		return result
+	}
+	for k, v := range r.Properties {
+		t.Setenv(strings.ReplaceAll(k, ".", "_"), v)
+	}
+	t.Setenv("map_input_file", "")
+	t.Setenv("mapreduce_map_input_file", "")
+
+	dir, err := workingDir(t, r.CacheFiles, r.Files)
+	if err != nil {
+		t.Errorf("%s", err)
+		return result
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Errorf("%s", err)
+		return result
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Errorf("%s", err)
+		return result
+	}
+	defer os.Chdir(wd)
+
+	var names []string
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var splits []inputSplit
+	for _, name := range names {
+		lines, err := readLines(inputs[name])
+		if err != nil {
+			t.Errorf("failed reading input %s %s", name, err)
+			return result
+		}
+		splits = append(splits, inputSplit{file: name, lines: lines})
+	}
+
+	for i, s := range r.Steps {
+		reducers := r.ReducerTasks
+		if sr, ok := s.(gomrjob.StepReducerTasksCount); ok {
+			reducers = sr.NumberReducerTasks()
+		}
+		_, combine := s.(gomrjob.Combiner)
+		o := Options{Reducers: reducers, Combine: combine, MapOnly: reducers == 0, Seed: 1}
+		step, err := runStep(t, s, splits, o)
+		if err != nil {
+			t.Errorf("%s", err)
+			return result
+		}
+		result.Steps = append(result.Steps, *step)
+
+		// the output of a step is read by a mapper for each reducer output
+		splits = nil
+		for p, part := range step.Parts {
+			lines, _ := readLines(bytes.NewReader(part))
+			splits = append(splits, inputSplit{file: fmt.Sprintf("step-%d/part-%05d", i, p), lines: lines})
+		}
+	}
+
+	if r.Output != "" && len(result.Steps) == len(r.Steps) {
+		if err := writeOutput(r.Output, result.Steps[len(result.Steps)-1].Parts, r.CompressOutput); err != nil {
+			t.Errorf("failed writing output %s", err)
+		}
+	}
+	return result
+}
+
+// setPassThroughOptions sets flags from options of the form "--name=value",
+// "--name value" or "--name" (for boolean flags) and restores them when the
+// test completes
+func setPassThroughOptions(t testing.TB, options []string) bool {
+	for i := 0; i < len(options); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(options[i], "-"), "=")
+		f := flag.Lookup(name)
+		if f == nil {
+			t.Errorf("unknown PassThroughOptions flag %q", options[i])
+			return false
+		}
+		if !hasValue {
+			if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+				value = "true"
+			} else if i+1 < len(options) {
+				i++
+				value = options[i]
+			}
+		}
+		prev := f.Value.String()
+		if err := f.Value.Set(value); err != nil {
+			t.Errorf("invalid PassThroughOptions %s %s", options[i], err)
+			return false
+		}
+		t.Cleanup(func() { f.Value.Set(prev) })
+	}
+	return true
+}
+
+// workingDir returns a temporary directory containing cacheFiles (-files)
+// and files (-file) as they would be in a task's working directory
+func workingDir(t testing.TB, cacheFiles, files []string) (string, error) {
+	dir := t.TempDir()
+	for _, f := range cacheFiles {
+		src, name, ok := strings.Cut(f, "#")
+		if !ok {
+			name = filepath.Base(src)
+		}
+		if err := copyFile(src, filepath.Join(dir, name)); err != nil {
+			return "", fmt.Errorf("failed copying cache file %s %w", f, err)
+		}
+	}
+	for _, f := range files {
+		if err := copyFile(f, filepath.Join(dir, filepath.Base(f))); err != nil {
+			return "", fmt.Errorf("failed copying file %s %w", f, err)
+		}
+	}
+	return dir, nil
+}
+
+// copyFile copies src (a local path or a path supported by mrfs) to dst
+func copyFile(src, dst string) error {
+	var rc io.ReadCloser
+	var err error
+	if strings.Contains(src, "://") {
+		rc, err = mrfs.Open(context.Background(), src)
+	} else {
+		rc, err = os.Open(src)
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writeOutput writes the output of each reducer to output/part-NNNNN (or
+// part-NNNNN.gz when compressed)
+func writeOutput(output string, parts [][]byte, compress bool) error {
+	ctx := context.Background()
+	output = strings.TrimSuffix(output, "/")
+	for i, part := range parts {
+		name := fmt.Sprintf("%s/part-%05d", output, i)
+		if compress {
+			name += ".gz"
+		}
+		w, err := mrfs.Create(ctx, name)
+		if err != nil {
+			return err
+		}
+		var pw io.WriteCloser = nopCloser{w}
+		if compress {
+			pw = gzip.NewWriter(w)
+		}
+		if _, err := pw.Write(part); err != nil {
+			w.Close()
+			return err
+		}
+		if err := pw.Close(); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,93 @@
+package mrtest
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/stretchr/testify/assert"
+)
+
+var suffix = flag.String("test-suffix", "", "suffix added to each key by taggedStep")
+
+// taggedStep emits "<word><suffix>\t<input file>:<prefix from cache file>:<property>"
+type taggedStep struct{}
+
+func (taggedStep) NumberReducerTasks() int { return 2 }
+
+func (taggedStep) Mapper(r io.Reader, w io.Writer) error {
+	prefix, err := os.ReadFile("prefix.txt")
+	if err != nil {
+		return err
+	}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		gomrjob.Counter("tagged", "lines", 1)
+		fmt.Fprintf(w, "%s%s\t%s:%s:%s\n", s.Text(), *suffix, os.Getenv("map_input_file"), strings.TrimSpace(string(prefix)), os.Getenv("my_property"))
+	}
+	return s.Err()
+}
+
+func (taggedStep) Reducer(r io.Reader, w io.Writer) error {
+	_, err := io.Copy(w, r)
+	return err
+}
+
+func TestRunRunner(t *testing.T) {
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	assert.Equal(t, os.WriteFile(cache, []byte("p\n"), 0644), nil)
+
+	counter := &wordCount{}
+	r := gomrjob.NewRunner()
+	r.Steps = []gomrjob.Step{taggedStep{}, counter}
+	r.ReducerTasks = 3
+	r.PassThroughOptions = []string{"--test-suffix=!"}
+	r.Properties = map[string]string{"my.property": "v"}
+	r.CacheFiles = []string{"file://" + cache + "#prefix.txt"}
+	r.Output = "file://" + filepath.Join(dir, "output")
+
+	result := RunRunner(t, r, map[string]io.Reader{
+		"in/b": strings.NewReader("y\n"),
+		"in/a": strings.NewReader("x\nz"),
+	})
+	assert.Equal(t, *suffix, "!")
+	assert.Equal(t, len(result.Steps), 2)
+
+	first := result.Steps[0]
+	assert.Equal(t, len(first.Parts), 2)
+	assert.Equal(t, sortedLines(first.Output), []string{"x!\tin/a:p:v", "y!\tin/b:p:v", "z!\tin/a:p:v"})
+	assert.Equal(t, first.Counters["tagged"]["lines"], int64(3))
+
+	second := result.Steps[1]
+	assert.Equal(t, len(second.Parts), 3)
+	assert.NotEqual(t, counter.combines, 0)
+	assert.Equal(t, sortedLines(result.Output()), sortedLines([]byte("in/a:p:v\t2\nin/b:p:v\t1\nx!\t1\ny!\t1\nz!\t1")))
+
+	var lines []string
+	for line, err := range r.OutputLines() {
+		assert.Equal(t, err, nil)
+		lines = append(lines, string(line))
+	}
+	assert.Equal(t, sortedLines([]byte(strings.Join(lines, "\n"))), sortedLines(result.Output()))
+}
+
+func TestRunRunnerMapOnly(t *testing.T) {
+	r := gomrjob.NewRunner()
+	r.Steps = []gomrjob.Step{&wordCount{}}
+	r.ReducerTasks = 0
+
+	result := RunRunner(t, r, map[string]io.Reader{
+		"in/a": strings.NewReader("b\na\n"),
+		"in/b": strings.NewReader("a\n"),
+	})
+	assert.Equal(t, len(result.Steps), 1)
+	// each mapper's output, unsorted and without reducing
+	assert.Equal(t, result.Steps[0].Parts, [][]byte{[]byte("b\t1\na\t1\n"), []byte("a\t1\n")})
+}
@@ -3,140 +3,304 @@ package mrtest
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
+	"math/rand"
+	"os"
 	"sort"
-	"sync"
 	"testing"
+	"time"
 
 	"github.com/jehiah/gomrjob"
 )
 
-type dataLines [][]byte
+// Options control how a step is run. The zero value runs one mapper, no
+// combiner and one reducer.
+type Options struct {
+	Mappers  int  // input lines are split across this many mappers
+	Reducers int  // map output is partitioned by key across this many reducers as hadoop's HashPartitioner would
+	Combine  bool // run the Combiner zero, one or many times over random splits of each mapper's output
+	MapOnly  bool // as hadoop does for jobs with 0 reducers, output each mapper's output (unsorted) without combining or reducing
 
-func (s dataLines) Len() int      { return len(s) }
-func (s dataLines) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+	// Seed seeds the random splits of map output and combiner invocations.
+	// When 0 a seed is chosen and logged so that failures can be reproduced.
+	Seed int64
 
-type sortedData struct{ dataLines }
+	// Unordered compares output lines regardless of order (i.e. for output from multiple reducers)
+	Unordered bool
+	// JSON compares keys and values as JSON, ignoring object field order and number formatting
+	JSON bool
 
-func (s sortedData) Less(i, j int) bool { return bytes.Compare(s.dataLines[i], s.dataLines[j]) == -1 }
+	taskError func(stage string) error // called before each task to inject failures
+}
+
+func (o Options) rand(t testing.TB) *rand.Rand {
+	seed := o.Seed
+	if seed == 0 && o.Combine {
+		seed = time.Now().UnixNano()
+		t.Logf("mrtest: using Options{Seed: %d}", seed)
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// Partition returns the reducer that hadoop's default HashPartitioner assigns
+// key to when there are n reducers
+func Partition(key []byte, n int) int {
+	// Text.hashCode() i.e. WritableComparator.hashBytes over signed bytes
+	var h int32 = 1
+	for _, b := range key {
+		h = 31*h + int32(int8(b))
+	}
+	return int(h&0x7fffffff) % n
+}
+
+// key returns the text before the first tab which hadoop streaming uses as the key
+func key(line []byte) []byte {
+	if i := bytes.IndexByte(line, '\t'); i != -1 {
+		return line[:i]
+	}
+	return bytes.TrimRight(line, "\n")
+}
 
-// a simple line sort (handling missing trailing newline on input)
-func sortPhase(in io.Reader, out io.Writer) error {
+// readLines reads newline terminated lines (adding a trailing newline to the last line if needed)
+func readLines(in io.Reader) ([][]byte, error) {
 	var data [][]byte
 	r := bufio.NewReader(in)
 	for {
 		line, err := r.ReadBytes('\n')
 		if len(line) >= 1 {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
 			data = append(data, line)
 		}
 		if err == io.EOF {
-			break
+			return data, nil
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
-	sort.Sort(sortedData{data})
-	for _, line := range data {
-		_, err := out.Write(bytes.TrimRight(line, "\n"))
-		if err != nil {
-			return err
+}
+
+// sortByKey sorts lines by key as the hadoop shuffle does. Values for a key
+// keep their relative order.
+func sortByKey(lines [][]byte) {
+	sort.SliceStable(lines, func(i, j int) bool { return bytes.Compare(key(lines[i]), key(lines[j])) == -1 })
+}
+
+// runPhase runs a map, combine or reduce function over lines
+func runPhase(f func(io.Reader, io.Writer) error, lines [][]byte) ([][]byte, error) {
+	var out bytes.Buffer
+	if err := f(bytes.NewReader(bytes.Join(lines, nil)), &out); err != nil {
+		return nil, err
+	}
+	return readLines(&out)
+}
+
+// splitLines splits lines into n contiguous chunks of similar size
+func splitLines(lines [][]byte, n int) [][][]byte {
+	o := make([][][]byte, n)
+	for i := range o {
+		o[i] = lines[len(lines)*i/n : len(lines)*(i+1)/n]
+	}
+	return o
+}
+
+// randomSplit splits lines into a random number of contiguous chunks
+func randomSplit(rng *rand.Rand, lines [][]byte) [][][]byte {
+	if len(lines) < 2 {
+		return [][][]byte{lines}
+	}
+	return splitLines(lines, 1+rng.Intn(min(len(lines), 4)))
+}
+
+// combine simulates hadoop running the combiner on each sorted spill of map
+// output and again when spills are merged
+func combine(rng *rand.Rand, combiner func(io.Reader, io.Writer) error, lines [][]byte) ([][]byte, error) {
+	return combineChunks(rng, combiner, randomSplit(rng, lines), 0)
+}
+
+// combineChunks sorts each chunk and runs combiner on it minRuns to minRuns+2
+// times, then half the time runs combiner again on the merged output, so that
+// it may see any subset of the values for a key, including values it has
+// already combined.
+func combineChunks(rng *rand.Rand, combiner func(io.Reader, io.Writer) error, chunks [][][]byte, minRuns int) ([][]byte, error) {
+	var o [][]byte
+	for _, chunk := range chunks {
+		chunk = append([][]byte(nil), chunk...)
+		sortByKey(chunk)
+		for n := minRuns + rng.Intn(3); n > 0 && len(chunk) > 0; n-- {
+			var err error
+			if chunk, err = runPhase(combiner, chunk); err != nil {
+				return nil, err
+			}
+			sortByKey(chunk)
 		}
-		_, err = out.Write([]byte("\n"))
-		if err != nil {
-			return err
+		o = append(o, chunk...)
+	}
+	if rng.Intn(2) == 0 && len(o) > 0 {
+		sortByKey(o)
+		return runPhase(combiner, o)
+	}
+	return o, nil
+}
+
+// RunStep runs a step over in and returns the output of each reducer, in
+// order, concatenated with surrounding whitespace removed. Failures are
+// reported with t.Errorf.
+func RunStep(t testing.TB, s gomrjob.Step, in io.Reader, o Options) []byte {
+	result := RunStepResult(t, s, in, o)
+	if result == nil {
+		return nil
+	}
+	return result.Output
+}
+
+// inputSplit is the input to one mapper
+type inputSplit struct {
+	file  string // set as map_input_file when not empty
+	lines [][]byte
+}
+
+// setInputFile sets map_input_file (and mapreduce_map_input_file) to name, as
+// hadoop does for a mapper, when name is not empty. It returns a func that
+// restores the previous values.
+func setInputFile(name string) (restore func()) {
+	if name == "" {
+		return func() {}
+	}
+	var restores []func()
+	for _, k := range []string{"map_input_file", "mapreduce_map_input_file"} {
+		prev, ok := os.LookupEnv(k)
+		os.Setenv(k, name)
+		restores = append(restores, func() {
+			if ok {
+				os.Setenv(k, prev)
+			} else {
+				os.Unsetenv(k)
+			}
+		})
+	}
+	return func() {
+		for _, f := range restores {
+			f()
 		}
 	}
-	return nil
 }
 
-func runReduceStep(t *testing.T, s gomrjob.Step, in io.Reader) []byte {
-	// TODO: test the combiner (if present)
-	// in -> map -> sort -> reduce -> out
-	var wg sync.WaitGroup
+// runStep runs s with a mapper for each split and returns the output of each
+// reducer and the counters reported
+func runStep(t testing.TB, s gomrjob.Step, splits []inputSplit, o Options) (*StepResult, error) {
+	reducers := max(o.Reducers, 1)
+	if o.MapOnly {
+		reducers = 0
+	}
+	rng := o.rand(t)
+	c, canCombine := s.(gomrjob.Combiner)
+	rep := newReport()
+	rep.before = o.taskError
+	defer recordReport(t, rep)
 
-	sortIn, mapOut := io.Pipe()
-	var sortInReader io.Reader
-	sortInReader = sortIn
-	reduceIn, sortOut := io.Pipe()
-	reduceOut := bytes.NewBuffer([]byte{})
-	wg.Add(2)
-	if _, ok := s.(gomrjob.Mapper); ok {
-		wg.Add(1)
-		go func() {
-			err := s.(gomrjob.Mapper).Mapper(in, mapOut)
+	// in -> map -> partition -> (combine) -> sort -> reduce -> out
+	var err error
+	var mapParts [][]byte
+	reduceIn := make([][][]byte, reducers)
+	for _, split := range splits {
+		mapOut := split.lines
+		if m, ok := s.(gomrjob.Mapper); ok {
+			restore := setInputFile(split.file)
+			mapOut, err = runPhase(rep.capture("mapper", m.Mapper), split.lines)
+			restore()
 			if err != nil {
-				t.Errorf("mapper failed with %s", err)
+				return nil, fmt.Errorf("mapper failed with %w", err)
 			}
-			mapOut.Close()
-			wg.Done()
-		}()
-	} else {
-		log.Printf("skipping mapper")
-		sortInReader = in
-	}
-	go func() {
-		err := sortPhase(sortInReader, sortOut)
-		if err != nil {
-			t.Errorf("sort failed with %s", err)
 		}
-		sortIn.Close()
-		sortOut.Close()
-		wg.Done()
-	}()
-	go func() {
-		err := s.Reducer(reduceIn, reduceOut)
+		if o.MapOnly {
+			mapParts = append(mapParts, bytes.Join(mapOut, nil))
+			continue
+		}
+		partitions := make([][][]byte, reducers)
+		for _, line := range mapOut {
+			p := Partition(key(line), reducers)
+			partitions[p] = append(partitions[p], line)
+		}
+		for p, partition := range partitions {
+			if o.Combine && canCombine && len(partition) > 0 {
+				if partition, err = combine(rng, rep.capture("combiner", c.Combiner), partition); err != nil {
+					return nil, fmt.Errorf("combiner failed with %w", err)
+				}
+			}
+			reduceIn[p] = append(reduceIn[p], partition...)
+		}
+	}
+
+	result := &StepResult{Parts: mapParts}
+	for _, partition := range reduceIn {
+		sortByKey(partition)
+		out, err := runPhase(rep.capture("reducer", s.Reducer), partition)
 		if err != nil {
-			t.Errorf("reduce failed with %s", err)
+			return nil, fmt.Errorf("reduce failed with %w", err)
 		}
-		reduceIn.Close()
-		wg.Done()
-	}()
-	wg.Wait()
-	result := bytes.TrimSpace(reduceOut.Bytes())
+		result.Parts = append(result.Parts, bytes.Join(out, nil))
+	}
+	result.Output = bytes.TrimSpace(bytes.Join(result.Parts, nil))
+	result.Counters, result.StageCounters, result.Status = rep.totals(), rep.stages, rep.status
+	return result, nil
+}
+
+// RunStepResult runs a step as RunStep does and returns its output along with
+// the counters and status messages reported
+func RunStepResult(t testing.TB, s gomrjob.Step, in io.Reader, o Options) *StepResult {
+	lines, err := readLines(in)
+	if err != nil {
+		t.Errorf("failed reading input %s", err)
+		return nil
+	}
+	var splits []inputSplit
+	for _, split := range splitLines(lines, max(o.Mappers, 1)) {
+		splits = append(splits, inputSplit{lines: split})
+	}
+	result, err := runStep(t, s, splits, o)
+	if err != nil {
+		t.Errorf("%s", err)
+	}
 	return result
 }
 
-func TestMapReduceSteps(t *testing.T, ss []gomrjob.Step, in io.Reader, out io.Reader) []byte {
+// RunSteps runs each step in order with the output of one step as the input
+// to the next and returns the final output
+func RunSteps(t testing.TB, ss []gomrjob.Step, in io.Reader, o Options) []byte {
 	var result []byte
 	for i, s := range ss {
 		if i == 0 {
-			result = runReduceStep(t, s, in)
+			result = RunStep(t, s, in, o)
 		} else {
-			result = runReduceStep(t, s, bytes.NewBuffer(result))
+			result = RunStep(t, s, bytes.NewBuffer(result), o)
 		}
 	}
-	outBytes, err := ioutil.ReadAll(out)
-	if err != nil {
-		t.Errorf("failed reading expected output %s", err)
-		return nil
-	}
-	outBytes = bytes.TrimSpace(outBytes)
-	if !bytes.Equal(result, outBytes) {
-		// TODO: iterate line by line for better feedback on errors
-		t.Logf("got output:\n%s", result)
-		t.Logf("expected output:\n%s", outBytes)
-		t.Errorf("output does not match expected output")
-	}
+	return result
+}
+
+func TestMapReduceSteps(t *testing.T, ss []gomrjob.Step, in io.Reader, out io.Reader) []byte {
+	return TestMapReduceStepsWithOptions(t, ss, in, out, Options{})
+}
+
+// TestMapReduceStepsWithOptions tests that steps run with Options generate a given output
+func TestMapReduceStepsWithOptions(t testing.TB, ss []gomrjob.Step, in io.Reader, out io.Reader, o Options) []byte {
+	result := RunSteps(t, ss, in, o)
+	compareOutput(t, result, out, o)
 	return result
 }
 
 // test that a geven step, and input generates a given output
 func TestMapReduceStep(t *testing.T, s gomrjob.Step, in io.Reader, out io.Reader) []byte {
-	outBytes, err := ioutil.ReadAll(out)
-	if err != nil {
-		t.Errorf("failed reading expected output %s", err)
-		return nil
-	}
-	outBytes = bytes.TrimSpace(outBytes)
-	result := runReduceStep(t, s, in)
-	if !bytes.Equal(result, outBytes) {
-		// TODO: iterate line by line for better feedback on errors
-		t.Logf("got output:\n%s", result)
-		t.Logf("expected output:\n%s", outBytes)
-		t.Errorf("output does not match expected output")
-	}
+	return TestMapReduceStepWithOptions(t, s, in, out, Options{})
+}
+
+// TestMapReduceStepWithOptions tests that a step run with Options generates a given output
+func TestMapReduceStepWithOptions(t testing.TB, s gomrjob.Step, in io.Reader, out io.Reader, o Options) []byte {
+	result := RunStep(t, s, in, o)
+	compareOutput(t, result, out, o)
 	return result
 }
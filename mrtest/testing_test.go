@@ -0,0 +1,155 @@
+package mrtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jehiah/gomrjob"
+	"github.com/jehiah/gomrjob/mrproto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		key      string
+		n        int
+		expected int
+	}{
+		{"a", 7, 2},
+		{`"hello"`, 7, 1},
+		{"\xff", 7, 2},
+		{"a long key that overflows", 7, 2},
+		{"a", 1, 0},
+	}
+	for _, tc := range tests {
+		if got := Partition([]byte(tc.key), tc.n); got != tc.expected {
+			t.Errorf("Partition(%q, %d) got %d expected %d", tc.key, tc.n, got, tc.expected)
+		}
+	}
+}
+
+// wordCount counts words and can be used with a combiner
+type wordCount struct {
+	combines int
+}
+
+func (w *wordCount) Mapper(r io.Reader, out io.Writer) error {
+	for line := range mrproto.RawInputProtocol(r) {
+		for _, word := range strings.Fields(string(line)) {
+			fmt.Fprintf(out, "%s\t1\n", word)
+		}
+	}
+	return nil
+}
+
+func (w *wordCount) Combiner(r io.Reader, out io.Writer) error {
+	w.combines++
+	return mrproto.Sum(r, out)
+}
+
+func (w *wordCount) Reducer(r io.Reader, out io.Writer) error {
+	return mrproto.Sum(r, out)
+}
+
+func sortedLines(b []byte) []string {
+	lines := strings.Split(string(b), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestRunStep(t *testing.T) {
+	in := "a b c\nb c\nc d e f\na\ng h\nc"
+	expected := "a\t2\nb\t2\nc\t4\nd\t1\ne\t1\nf\t1\ng\t1\nh\t1"
+
+	step := &wordCount{}
+	TestMapReduceStepWithOptions(t, step, strings.NewReader(in), strings.NewReader(expected), Options{})
+	assert.Equal(t, step.combines, 0)
+
+	for seed := int64(1); seed < 20; seed++ {
+		o := Options{Mappers: 3, Reducers: 4, Combine: true, Seed: seed}
+		result := RunStep(t, step, strings.NewReader(in), o)
+		assert.Equal(t, sortedLines(result), sortedLines([]byte(expected)), "seed %d", seed)
+	}
+	assert.NotEqual(t, step.combines, 0)
+}
+
+func TestRunStepPartitions(t *testing.T) {
+	// each reducer sees only the keys partitioned to it, and output is in reducer order
+	step := &wordCount{}
+	result := RunStep(t, step, strings.NewReader("a b c d e f g"), Options{Reducers: 3})
+	var expected [][]byte
+	for p := 0; p < 3; p++ {
+		for _, k := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+			if Partition([]byte(k), 3) == p {
+				expected = append(expected, []byte(k+"\t1"))
+			}
+		}
+	}
+	assert.Equal(t, string(result), string(bytes.Join(expected, []byte("\n"))))
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		got      string
+		o        Options
+		diff     bool
+		keys     []string
+	}{
+		{"equal", "a\t1\nb\t2\n", "a\t1\nb\t2", Options{}, false, nil},
+		{"value", "a\t1\nb\t2\nc\t3", "a\t1\nb\t5\nc\t3", Options{}, true, []string{"b"}},
+		{"order", "a\t1\nb\t2", "b\t2\na\t1", Options{}, true, []string{"b"}},
+		{"unordered", "a\t1\nb\t2", "b\t2\na\t1", Options{Unordered: true}, false, nil},
+		{"json", `{"a":1,"b":[1.0]}` + "\t2.50", `{"b":[1],"a":1}` + "\t2.5", Options{JSON: true}, false, nil},
+		{"json value", `"k"` + "\t" + `{"x":1}`, `"k"` + "\t" + `{"x":2}`, Options{JSON: true}, true, []string{`"k"`}},
+		{"json large ids", `{"id":9007199254740993}`, `{"id":9007199254740992}`, Options{JSON: true}, true, []string{`{"id":9007199254740993}`, `{"id":9007199254740992}`}},
+		{"json large id", `[9007199254740993, 1.0]`, `[9007199254740993,1]`, Options{JSON: true}, false, nil},
+		{"json trailing", `{"a":1} x`, `{"a":1}`, Options{JSON: true}, true, []string{`{"a":1} x`, `{"a":1}`}},
+		{"not json", "a b\t01", "a b\t1", Options{JSON: true}, true, []string{"a b"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := Diff([]byte(tc.expected), []byte(tc.got), tc.o)
+			assert.Equal(t, diff != "", tc.diff, diff)
+			assert.Equal(t, diffKeys(diff), tc.keys)
+		})
+	}
+}
+
+func TestStepSnapshots(t *testing.T) {
+	step := &wordCount{}
+	in := "a b\nb"
+	rt := recordingT{TB: t}
+	outputs := TestMapReduceStepSnapshots(&rt, []gomrjob.Step{step, step}, strings.NewReader(in),
+		[]io.Reader{strings.NewReader("a\t1\nb\t3"), nil}, Options{})
+	assert.Equal(t, len(outputs), 2)
+	assert.Equal(t, string(outputs[0]), "a\t1\nb\t2")
+	assert.Equal(t, rt.errors, []string{`step 0 output does not match expected output for keys ["b"]`})
+}
+
+// inputFileStep outputs map_input_file for each line
+type inputFileStep struct{}
+
+func (inputFileStep) Mapper(r io.Reader, w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s\t1\n", os.Getenv("map_input_file"))
+	return err
+}
+
+func (inputFileStep) Reducer(r io.Reader, w io.Writer) error { return mrproto.Sum(r, w) }
+
+func TestRunStepInputFile(t *testing.T) {
+	t.Setenv("map_input_file", "previous")
+	splits := []inputSplit{{file: "a", lines: [][]byte{[]byte("x\n")}}, {file: "b", lines: [][]byte{[]byte("y\n")}}}
+	result, err := runStep(t, inputFileStep{}, splits, Options{})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, string(result.Output), "a\t1\nb\t1")
+	assert.Equal(t, os.Getenv("map_input_file"), "previous")
+	_, ok := os.LookupEnv("mapreduce_map_input_file")
+	assert.False(t, ok)
+}
@@ -0,0 +1,134 @@
+package gomrjob
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/jehiah/gomrjob/mrfs"
+)
+
+// OutputFiles returns the part-* files of the final step output in order
+func (r *Runner) OutputFiles() ([]*mrfs.FileInfo, error) {
+	if r.Output == "" {
+		return nil, errors.New("no output path; Run has not been called")
+	}
+	output := strings.TrimSuffix(hdfs.AbsolutePath(r.Output, r.defaultProto), "/")
+	return mrfs.Glob(context.Background(), output+"/part-*")
+}
+
+// OpenOutput returns a reader for the final output of the job after Run. The
+// part-* files are read in order and decompressed when CompressOutput is set.
+//
+// The output can be decoded with an mrproto input protocol
+//
+//	rc, err := runner.OpenOutput()
+//	...
+//	for kv := range mrproto.RawJsonInternalInputProtocol(rc) {
+func (r *Runner) OpenOutput() (io.ReadCloser, error) {
+	files, err := r.OutputFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no output files found in %s", r.Output)
+	}
+	return &partReader{files: files, gzip: r.CompressOutput}, nil
+}
+
+// OutputLines iterates over the lines (without a trailing newline) of the final
+// output of the job after Run. Iteration stops after the first error.
+func (r *Runner) OutputLines() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		rc, err := r.OpenOutput()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rc.Close()
+		br := bufio.NewReaderSize(rc, 1024*1024*2)
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(line) > 0 {
+				if !yield(bytes.TrimRight(line, "\n"), nil) {
+					return
+				}
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+// partReader reads each file in sequence, opening them as needed
+type partReader struct {
+	files []*mrfs.FileInfo
+	gzip  bool
+	f     io.ReadCloser
+	r     io.Reader
+}
+
+func (p *partReader) next() error {
+	file := p.files[0]
+	p.files = p.files[1:]
+	f, err := mrfs.Open(context.Background(), file.Path())
+	if err != nil {
+		return err
+	}
+	p.f, p.r = f, f
+	if p.gzip || strings.HasSuffix(file.Name(), ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			p.f = nil
+			return fmt.Errorf("%s %w", file.Path(), err)
+		}
+		p.r = gz
+	}
+	return nil
+}
+
+func (p *partReader) Read(b []byte) (int, error) {
+	for {
+		if p.f == nil {
+			if len(p.files) == 0 {
+				return 0, io.EOF
+			}
+			if err := p.next(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := p.r.Read(b)
+		if err == io.EOF {
+			err = p.f.Close()
+			p.f = nil
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (p *partReader) Close() error {
+	p.files = nil
+	if p.f == nil {
+		return nil
+	}
+	err := p.f.Close()
+	p.f = nil
+	return err
+}
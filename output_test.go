@@ -0,0 +1,33 @@
+package gomrjob
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutput(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "part-00000"), []byte("\"a\"\t1\n\"b\"\t2\n"), 0644)
+	assert.Equal(t, err, nil)
+	f, err := os.Create(filepath.Join(dir, "part-00001.gz"))
+	assert.Equal(t, err, nil)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte("\"c\"\t3")) // nolint:errcheck
+	gz.Close()
+	f.Close()
+	err = os.WriteFile(filepath.Join(dir, "_SUCCESS"), nil, 0644)
+	assert.Equal(t, err, nil)
+
+	r := NewRunner()
+	r.Output = "file://" + dir
+	var lines []string
+	for line, err := range r.OutputLines() {
+		assert.Equal(t, err, nil)
+		lines = append(lines, string(line))
+	}
+	assert.Equal(t, lines, []string{"\"a\"\t1", "\"b\"\t2", "\"c\"\t3"})
+}
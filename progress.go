@@ -0,0 +1,64 @@
+package gomrjob
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jehiah/gomrjob/hdfs"
+)
+
+// StepProgress is a progress update for one step of a Runner
+type StepProgress struct {
+	Step  int // index into Runner.Steps
+	Steps int // len(Runner.Steps)
+	Name  string
+	hdfs.Progress
+}
+
+// ProgressListener receives progress updates while Run is submitting steps
+type ProgressListener interface {
+	StepProgress(StepProgress)
+}
+
+// ProgressFunc adapts a function to a ProgressListener
+type ProgressFunc func(StepProgress)
+
+func (f ProgressFunc) StepProgress(p StepProgress) { f(p) }
+
+// NewProgressLogger returns a ProgressListener that writes a line to w as each
+// step progresses. It is the default for Runner.Progress
+func NewProgressLogger(w io.Writer) ProgressListener {
+	return &progressLogger{w: w}
+}
+
+type progressLogger struct {
+	mu   sync.Mutex
+	w    io.Writer
+	last string
+}
+
+func (l *progressLogger) StepProgress(p StepProgress) {
+	line := fmt.Sprintf("step %d/%d %s %s map %.0f%% reduce %.0f%%", p.Step+1, p.Steps, p.JobID, p.State, p.Map, p.Reduce)
+	if p.Map == 0 && p.Reduce == 0 && p.Overall != 0 {
+		line = fmt.Sprintf("step %d/%d %s %s %.0f%%", p.Step+1, p.Steps, p.JobID, p.State, p.Overall)
+	}
+	if p.RunningTasks > 0 {
+		line += fmt.Sprintf(" running:%d", p.RunningTasks)
+	}
+	if p.FailedTasks > 0 {
+		line += fmt.Sprintf(" failed:%d", p.FailedTasks)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if line == l.last {
+		return
+	}
+	l.last = line
+	line += fmt.Sprintf(" elapsed:%s", p.Elapsed.Round(time.Second))
+	if eta := p.ETA(); eta > 0 {
+		line += fmt.Sprintf(" eta:%s", eta)
+	}
+	fmt.Fprintln(l.w, line)
+}
@@ -0,0 +1,28 @@
+package gomrjob
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressLogger(t *testing.T) {
+	var out bytes.Buffer
+	l := NewProgressLogger(&out)
+	p := StepProgress{Step: 0, Steps: 2, Progress: hdfs.Progress{JobID: "job_1", State: "RUNNING", Map: 50, Elapsed: time.Minute}}
+	l.StepProgress(p)
+	p.Elapsed = 2 * time.Minute
+	l.StepProgress(p) // unchanged progress is not logged again
+	p.Map, p.RunningTasks, p.FailedTasks = 100, 2, 1
+	l.StepProgress(p)
+	assert.Equal(t, out.String(), `step 1/2 job_1 RUNNING map 50% reduce 0% elapsed:1m0s eta:3m0s
+step 1/2 job_1 RUNNING map 100% reduce 0% running:2 failed:1 elapsed:2m0s eta:2m0s
+`)
+
+	out.Reset()
+	l.StepProgress(StepProgress{Step: 1, Steps: 2, Progress: hdfs.Progress{JobID: "job_2", State: "RUNNING", Overall: 25, Elapsed: time.Minute}})
+	assert.Equal(t, out.String(), "step 2/2 job_2 RUNNING 25% elapsed:1m0s eta:3m0s\n")
+}
@@ -0,0 +1,220 @@
+package gomrjob
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RemoteLogFilter controls which task logs are printed by the submitting process
+type RemoteLogFilter struct {
+	Stages   []string       // only print logs from these stages (mapper, combiner, reducer)
+	Steps    []int          // only print logs from these steps
+	MinLevel string         // only print records at or above this level (DEBUG, INFO, WARN, ERROR)
+	Match    *regexp.Regexp // only print records with a message matching this expression
+
+	// SampleLines prints at most this many lines from each task; 0 for no limit
+	SampleLines int
+
+	// Dir, when set, is a local directory where all records (regardless of the
+	// filters above) are written to one file per task as
+	// <Dir>/step_<n>/<stage>/<task>.log
+	Dir string
+}
+
+var levelRank = map[string]int{"DEBUG": -4, "INFO": 0, "WARN": 4, "ERROR": 8}
+
+func levelAtLeast(level, min string) bool {
+	return levelRank[strings.ToUpper(level)] >= levelRank[strings.ToUpper(min)]
+}
+
+func (f RemoteLogFilter) match(rec logRecord) bool {
+	switch {
+	case len(f.Stages) > 0 && !slices.Contains(f.Stages, rec.Stage):
+		return false
+	case len(f.Steps) > 0 && !slices.Contains(f.Steps, rec.Step):
+		return false
+	case f.MinLevel != "" && !levelAtLeast(rec.Level, f.MinLevel):
+		return false
+	case f.Match != nil && !f.Match.MatchString(rec.Message):
+		return false
+	}
+	return true
+}
+
+// taskLog tracks the records received from a single task
+type taskLog struct {
+	rec        logRecord // the first record, for task metadata
+	printed    int
+	suppressed int
+	errors     int
+	lastError  string
+	path       string   // the per-task log file when RemoteLogFilter.Dir is set
+	file       *os.File // path, while it's one of the most recently written files
+}
+
+// maxOpenTaskFiles limits the task log files kept open at once so that jobs
+// with many thousands of tasks don't run out of file descriptors
+var maxOpenTaskFiles = 64
+
+func (rec logRecord) taskKey() string {
+	if rec.Attempt != "" {
+		return rec.Attempt
+	}
+	return fmt.Sprintf("%s-%s-%d", rec.Host, rec.Stage, rec.Step)
+}
+
+// remoteLogPrinter writes records to w one whole line at a time, applying
+// RemoteLogFilter and keeping per-task statistics for a summary on Close
+type remoteLogPrinter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	filter RemoteLogFilter
+	tasks  map[string]*taskLog
+	open   []*taskLog // tasks with an open file, least recently written first
+}
+
+func newRemoteLogPrinter(w io.Writer, filter RemoteLogFilter) *remoteLogPrinter {
+	return &remoteLogPrinter{w: w, filter: filter, tasks: make(map[string]*taskLog)}
+}
+
+func (p *remoteLogPrinter) emit(rec logRecord) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := rec.taskKey()
+	t, ok := p.tasks[key]
+	if !ok {
+		t = &taskLog{rec: rec}
+		p.tasks[key] = t
+		if p.filter.Dir != "" {
+			t.path = p.taskFilePath(rec)
+		}
+	}
+	if rec.Level == "ERROR" {
+		t.errors++
+		t.lastError = rec.Message
+	}
+	if f := p.taskFile(t); f != nil {
+		fmt.Fprintf(f, "%s %s %s\n", rec.Time.Format("2006-01-02T15:04:05.000Z07:00"), rec.Level, rec.Message)
+	}
+	if !p.filter.match(rec) {
+		return
+	}
+	if p.filter.SampleLines > 0 && t.printed >= p.filter.SampleLines {
+		t.suppressed++
+		return
+	}
+	t.printed++
+
+	prefix := rec.prefix()
+	var b bytes.Buffer
+	for _, line := range strings.Split(rec.Message, "\n") {
+		b.WriteString(prefix)
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	p.w.Write(b.Bytes()) // nolint:errcheck
+}
+
+func (p *remoteLogPrinter) taskFilePath(rec logRecord) string {
+	dir := filepath.Join(p.filter.Dir, fmt.Sprintf("step_%d", rec.Step), rec.Stage)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(p.w, "failed creating task log dir %s\n", err)
+		return ""
+	}
+	name := strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(rec.taskKey())
+	return filepath.Join(dir, name+".log")
+}
+
+// taskFile returns the open log file for t, (re)opening it for append and
+// closing the least recently written file when over maxOpenTaskFiles
+func (p *remoteLogPrinter) taskFile(t *taskLog) *os.File {
+	if t.path == "" {
+		return nil
+	}
+	if t.file != nil {
+		i := slices.Index(p.open, t)
+		p.open = append(slices.Delete(p.open, i, i+1), t)
+		return t.file
+	}
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(p.w, "failed creating task log %s\n", err)
+		t.path = ""
+		return nil
+	}
+	t.file = f
+	p.open = append(p.open, t)
+	if len(p.open) > maxOpenTaskFiles {
+		p.open[0].file.Close()
+		p.open[0].file = nil
+		p.open = p.open[1:]
+	}
+	return f
+}
+
+// emitLine decodes a framed record, falling back to printing unrecognized lines as is
+func (p *remoteLogPrinter) emitLine(line []byte) {
+	var rec logRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.w.Write(line) // nolint:errcheck
+		return
+	}
+	p.emit(rec)
+}
+
+// Close closes per-task log files and prints a summary of tasks that logged
+// errors or had lines suppressed by sampling
+func (p *remoteLogPrinter) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var keys []string
+	var withErrors, suppressed, suppressedTasks int
+	for key, t := range p.tasks {
+		keys = append(keys, key)
+		if t.errors > 0 {
+			withErrors++
+		}
+		if t.suppressed > 0 {
+			suppressed += t.suppressed
+			suppressedTasks++
+		}
+	}
+	for _, t := range p.open {
+		t.file.Close()
+		t.file = nil
+	}
+	p.open = nil
+	sort.Strings(keys)
+	if withErrors > 0 {
+		fmt.Fprintf(p.w, "remote log summary: %d of %d tasks logged errors\n", withErrors, len(p.tasks))
+		for _, key := range keys {
+			if t := p.tasks[key]; t.errors > 0 {
+				fmt.Fprintf(p.w, "  %s%d errors, last: %s\n", t.rec.prefix(), t.errors, firstLine(t.lastError))
+			}
+		}
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(p.w, "remote log summary: %d lines from %d tasks not shown (SampleLines=%d)\n", suppressed, suppressedTasks, p.filter.SampleLines)
+	}
+	if p.filter.Dir != "" && len(p.tasks) > 0 {
+		fmt.Fprintf(p.w, "remote log summary: logs from %d tasks written to %s\n", len(p.tasks), p.filter.Dir)
+	}
+	p.tasks = make(map[string]*taskLog)
+	return nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
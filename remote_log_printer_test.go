@@ -0,0 +1,71 @@
+package gomrjob
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteLogPrinter(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	p := newRemoteLogPrinter(&out, RemoteLogFilter{
+		Stages:      []string{"mapper"},
+		Match:       regexp.MustCompile("^keep"),
+		SampleLines: 2,
+		Dir:         dir,
+	})
+	m := logRecord{Host: "h", Stage: "mapper", Step: 0, Attempt: "attempt_1_0001_m_000000_0", Level: "INFO"}
+	r := logRecord{Host: "h", Stage: "reducer", Step: 0, Attempt: "attempt_1_0001_r_000000_0", Level: "INFO"}
+	for _, msg := range []string{"keep 1", "skip", "keep 2", "keep 3"} {
+		m.Message = msg
+		p.emit(m)
+	}
+	r.Message, r.Level = "Error: failed", "ERROR"
+	p.emit(r)
+	p.Close()
+
+	assert.Equal(t, out.String(), `[h mapper:0 attempt_1_0001_m_000000_0] keep 1
+[h mapper:0 attempt_1_0001_m_000000_0] keep 2
+remote log summary: 1 of 2 tasks logged errors
+  [h reducer:0 attempt_1_0001_r_000000_0] 1 errors, last: Error: failed
+remote log summary: 1 lines from 1 tasks not shown (SampleLines=2)
+remote log summary: logs from 2 tasks written to `+dir+`
+`)
+
+	data, err := os.ReadFile(filepath.Join(dir, "step_0", "mapper", "attempt_1_0001_m_000000_0.log"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bytes.Count(data, []byte("\n")), 4)
+}
+
+func TestRemoteLogPrinterOpenFiles(t *testing.T) {
+	defer func(n int) { maxOpenTaskFiles = n }(maxOpenTaskFiles)
+	maxOpenTaskFiles = 2
+	dir := t.TempDir()
+	var out bytes.Buffer
+	p := newRemoteLogPrinter(&out, RemoteLogFilter{Dir: dir})
+	for i := 0; i < 3; i++ {
+		for task := 0; task < 5; task++ {
+			p.emit(logRecord{Host: "h", Stage: "mapper", Attempt: fmt.Sprintf("attempt_%d", task), Message: "line"})
+			assert.True(t, len(p.open) <= 2)
+		}
+	}
+	p.Close()
+
+	for task := 0; task < 5; task++ {
+		data, err := os.ReadFile(filepath.Join(dir, "step_0", "mapper", fmt.Sprintf("attempt_%d.log", task)))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, bytes.Count(data, []byte("\n")), 3)
+	}
+}
+
+func TestGuessLevel(t *testing.T) {
+	assert.Equal(t, guessLevel("Error: bad input"), "ERROR")
+	assert.Equal(t, guessLevel("WARNING: skipping"), "WARN")
+	assert.Equal(t, guessLevel("starting mapper step 0"), "INFO")
+}
@@ -1,12 +1,233 @@
 package gomrjob
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+type syncBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.String()
+}
+
 func TestListen(t *testing.T) {
-	addr := startRemoteLogListner()
-	assert.NotEqual(t, addr, "0.0.0.0:0")
+	ln, err := startRemoteLogListener(newRemoteLogPrinter(io.Discard, RemoteLogFilter{}), "", "")
+	assert.Equal(t, err, nil)
+	defer ln.Close()
+	assert.NotEqual(t, ln.Addr(), "0.0.0.0:0")
+}
+
+func TestRemoteLogging(t *testing.T) {
+	var out syncBuffer
+	ln, err := startRemoteLogListener(newRemoteLogPrinter(&out, RemoteLogFilter{}), "", "")
+	assert.Equal(t, err, nil)
+
+	meta := logRecord{Host: "worker1", Stage: "mapper", Step: 1, Attempt: "attempt_1_0001_m_000003_0"}
+	w := newRemoteLogWriter(ln.Addr(), meta, RemoteLogOptions{})
+	logger := log.New(w, "", 0)
+	logger.Printf("hello")
+	logger.Printf("two\nlines")
+	w.Close()
+	ln.Close()
+
+	assert.Equal(t, out.String(), `[worker1 mapper:1 attempt_1_0001_m_000003_0] hello
+[worker1 mapper:1 attempt_1_0001_m_000003_0] two
+[worker1 mapper:1 attempt_1_0001_m_000003_0] lines
+`)
+}
+
+func TestRemoteLogRateLimit(t *testing.T) {
+	w := newLogWriter(logRecord{}, RemoteLogOptions{RateLimit: 0.001, Burst: 2}.withDefaults())
+	for i := 0; i < 10; i++ {
+		w.Write([]byte("line\n")) // nolint:errcheck
+	}
+	assert.Equal(t, len(w.records), 2)
+	assert.Equal(t, w.dropped.Load(), int64(8))
+}
+
+// fastRemoteLogRetries shortens the backoff and redial interval of remote log writers
+func fastRemoteLogRetries(t *testing.T) {
+	backoff, redial := remoteLogBackoff, remoteLogRedialInterval
+	remoteLogBackoff, remoteLogRedialInterval = time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { remoteLogBackoff, remoteLogRedialInterval = backoff, redial })
+}
+
+func TestRemoteLogUnreachable(t *testing.T) {
+	fastRemoteLogRetries(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var stderr syncBuffer
+	w := newLogWriter(logRecord{Stage: "mapper"}, RemoteLogOptions{}.withDefaults())
+	w.fallback = &stderr
+	go w.sendTCP(addr)
+	logger := log.New(w, "", 0)
+	logger.Printf("queued")
+	for !w.failed.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	logger.Printf("after")
+	w.Close()
+
+	assert.Contains(t, stderr.String(), "failed connecting to remote logger")
+	assert.Contains(t, stderr.String(), "queued\nafter\n")
+	assert.Equal(t, w.dropped.Load(), int64(0))
+}
+
+func TestRemoteLogRetry(t *testing.T) {
+	backoff := remoteLogBackoff
+	remoteLogBackoff = 20 * time.Millisecond
+	defer func() { remoteLogBackoff = backoff }()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// records are buffered while retrying and sent once connected
+	var stderr syncBuffer
+	w := newLogWriter(logRecord{Host: "worker1", Stage: "mapper"}, RemoteLogOptions{}.withDefaults())
+	w.fallback = &stderr
+	go w.sendTCP(addr)
+	logger := log.New(w, "", 0)
+	logger.Printf("first")
+	logger.Printf("second")
+	time.Sleep(30 * time.Millisecond)
+
+	var out syncBuffer
+	remote, err := startRemoteLogListener(newRemoteLogPrinter(&out, RemoteLogFilter{}), addr, "")
+	assert.Equal(t, err, nil)
+	w.Close()
+	remote.Close()
+
+	assert.Equal(t, out.String(), "[worker1 mapper:0] first\n[worker1 mapper:0] second\n")
+	assert.Equal(t, stderr.String(), "")
+}
+
+func TestRemoteLogReconnect(t *testing.T) {
+	fastRemoteLogRetries(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var stderr syncBuffer
+	w := newLogWriter(logRecord{Host: "worker1", Stage: "mapper"}, RemoteLogOptions{}.withDefaults())
+	w.fallback = &stderr
+	go w.sendTCP(addr)
+	logger := log.New(w, "", 0)
+	logger.Printf("unreachable")
+	for !w.failed.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the writer reconnects once the listener is available
+	var out syncBuffer
+	remote, err := startRemoteLogListener(newRemoteLogPrinter(&out, RemoteLogFilter{}), addr, "")
+	assert.Equal(t, err, nil)
+	for w.failed.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	logger.Printf("reconnected")
+	w.Close()
+	remote.Close()
+
+	assert.Contains(t, stderr.String(), "unreachable\n")
+	assert.Contains(t, stderr.String(), "reconnected to remote logger")
+	assert.Equal(t, out.String(), "[worker1 mapper:0] reconnected\n")
+}
+
+func TestRemoteLogAdvertiseAddr(t *testing.T) {
+	ln, err := startRemoteLogListener(newRemoteLogPrinter(io.Discard, RemoteLogFilter{}), "127.0.0.1:0", "relay.example.com:9123")
+	assert.Equal(t, err, nil)
+	defer ln.Close()
+	assert.Equal(t, ln.Addr(), "relay.example.com:9123")
+}
+
+func TestRemoteLogStorage(t *testing.T) {
+	dir := "file://" + t.TempDir() + "/logs"
+	var out syncBuffer
+	tailer := startRemoteLogTailer(newRemoteLogPrinter(&out, RemoteLogFilter{}), dir, time.Hour)
+
+	meta := logRecord{Host: "worker1", Stage: "reducer", Step: 0}
+	w := newStorageLogWriter(dir, meta, RemoteLogOptions{})
+	logger := log.New(w, "", 0)
+	logger.Printf("first")
+	logger.Printf("second")
+	w.Close()
+
+	tailer.Close()
+	assert.Equal(t, out.String(), `[worker1 reducer:0] first
+[worker1 reducer:0] second
+`)
+}
+
+func TestRemoteLogStorageHDFS(t *testing.T) {
+	// a fake `hadoop fs -put - <remote>` that stores each upload in dir
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "bin"), 0755)
+	script := fmt.Sprintf("#!/bin/sh\ncat > %s/put.$$\n", dir)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "bin", "hadoop"), []byte(script), 0755), nil)
+	t.Setenv("HADOOP_HOME", dir)
+
+	w := newStorageLogWriter("hdfs:///logs", logRecord{Stage: "mapper"}, RemoteLogOptions{FlushInterval: 5 * time.Millisecond})
+	log.SetOutput(w)
+	defer log.SetOutput(os.Stderr)
+	log.Printf("hello")
+	time.Sleep(50 * time.Millisecond)
+	log.SetOutput(os.Stderr)
+	w.Close()
+
+	// the put for "hello" must not log a record that causes another put
+	puts, _ := filepath.Glob(filepath.Join(dir, "put.*"))
+	assert.Equal(t, len(puts), 1)
+}
+
+func TestRemoteLogTailerHDFS(t *testing.T) {
+	// a fake `hadoop fs` that lists and cats one log file
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "bin"), 0755)
+	record := `{"host":"worker1","stage":"mapper","step":0,"msg":"hello"}`
+	script := fmt.Sprintf(`#!/bin/sh
+case "$2" in
+-ls) echo "-rw-r--r--   3 user group 10 2024-01-02 03:04 /logs/mapper-0-a-00000.jsonl" ;;
+-cat) echo '%s' ;;
+esac
+`, record)
+	assert.Equal(t, os.WriteFile(filepath.Join(dir, "bin", "hadoop"), []byte(script), 0755), nil)
+	t.Setenv("HADOOP_HOME", dir)
+
+	var logged syncBuffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	var out syncBuffer
+	tailer := startRemoteLogTailer(newRemoteLogPrinter(&out, RemoteLogFilter{}), "hdfs:///logs", time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	tailer.Close()
+
+	assert.Equal(t, out.String(), "[worker1 mapper:0] hello\n")
+	assert.NotContains(t, logged.String(), "hadoop")
 }
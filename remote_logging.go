@@ -1,76 +1,620 @@
 package gomrjob
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/jehiah/gomrjob/mrfs"
 )
 
-// redirect log to a remote port
-func dialRemoteLogger(addr string) (io.Writer, error) {
-	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
-	if err != nil {
-		return nil, err
+// RemoteLogTransport selects how task logs reach the process that submitted the job
+type RemoteLogTransport int8
+
+const (
+	RemoteLogAuto     RemoteLogTransport = iota // RemoteLogTCP for HDFS jobs, RemoteLogStorage for Dataproc
+	RemoteLogTCP                                // tasks connect to a listener in the submitting process
+	RemoteLogStorage                            // tasks write logs to the job temp dir which the submitter polls
+	RemoteLogDisabled                           // task logs are only available from the cluster
+)
+
+// RemoteLogOptions configures how map reduce tasks send log output back to the
+// process that submitted the job
+type RemoteLogOptions struct {
+	Transport  RemoteLogTransport
+	RateLimit  float64 // log lines per second per task; default 100
+	Burst      int     // lines allowed above RateLimit in a burst; default 1000
+	BufferSize int     // lines buffered while (re)connecting; default 10000
+
+	// RemoteLogTCP: ListenAddr is the local address to listen on (default
+	// a random port). AdvertiseAddr is the address tasks connect to (default
+	// hostname:port) which can be a relay or a port forwarded through a firewall
+	ListenAddr    string
+	AdvertiseAddr string
+
+	// RemoteLogStorage: how often tasks write buffered logs (default 10s),
+	// and how often the submitter checks for them (default 5s)
+	FlushInterval time.Duration
+	PollInterval  time.Duration
+
+	Filter RemoteLogFilter
+}
+
+func (o RemoteLogOptions) withDefaults() RemoteLogOptions {
+	if o.RateLimit <= 0 {
+		o.RateLimit = 100
+	}
+	if o.Burst <= 0 {
+		o.Burst = 1000
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = 10000
+	}
+	if o.FlushInterval <= 0 {
+		o.FlushInterval = 10 * time.Second
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 5 * time.Second
 	}
-	conn, err := net.DialTimeout("tcp", tcpAddr.String(), time.Duration(5)*time.Second)
+	return o
+}
+
+// logRecord is a single log entry sent from a task. Records are framed as one
+// json object per line so that entries from concurrent tasks never interleave.
+type logRecord struct {
+	Time    time.Time `json:"ts"`
+	Host    string    `json:"host"`
+	Stage   string    `json:"stage"`
+	Step    int       `json:"step"`
+	TaskID  string    `json:"task,omitempty"`
+	Attempt string    `json:"attempt,omitempty"`
+	Level   string    `json:"level,omitempty"`
+	Message string    `json:"msg"`
+}
+
+// newTaskLogRecord returns a logRecord with the metadata for the running task.
+// Hadoop streaming exposes job configuration as environment variables with '.' replaced by '_'
+func newTaskLogRecord() logRecord {
+	hostname, _ := os.Hostname()
+	rec := logRecord{
+		Host:    hostname,
+		Stage:   *stage,
+		Step:    *step,
+		TaskID:  os.Getenv("mapreduce_task_id"),
+		Attempt: os.Getenv("mapreduce_task_attempt_id"),
+	}
+	if rec.Attempt == "" {
+		rec.Attempt = os.Getenv("mapred_task_id")
+	}
+	return rec
+}
+
+// prefix returns the "[host stage:step attempt] " prefix used when displaying a record
+func (rec logRecord) prefix() string {
+	if rec.Attempt != "" {
+		return fmt.Sprintf("[%s %s:%d %s] ", rec.Host, rec.Stage, rec.Step, rec.Attempt)
+	}
+	return fmt.Sprintf("[%s %s:%d] ", rec.Host, rec.Stage, rec.Step)
+}
+
+// guessLevel infers a level for messages from the log package, which has no levels
+func guessLevel(msg string) string {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "error"), strings.Contains(lower, "panic"), strings.Contains(lower, "fatal"):
+		return "ERROR"
+	case strings.Contains(lower, "warn"):
+		return "WARN"
+	}
+	return "INFO"
+}
+
+// remoteLogWriter is an io.Writer (for use with log.SetOutput) that sends each
+// write as a logRecord to the remote log listener. Writes never block: records
+// are buffered while connecting, and lines over the rate limit or beyond the
+// buffer are dropped (and counted). If the listener can't be reached after
+// retrying with backoff, records are written to fallback (stderr) so they
+// remain in the task logs until a periodic reconnect succeeds.
+type remoteLogWriter struct {
+	meta     logRecord
+	records  chan []byte
+	dropped  atomic.Int64
+	done     chan struct{}
+	stop     chan struct{}
+	fallback io.Writer
+	failed   atomic.Bool // set once records are written to fallback
+
+	mu     sync.Mutex // guards closed and the rate limit
+	closed bool
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLogWriter(meta logRecord, o RemoteLogOptions) *remoteLogWriter {
+	return &remoteLogWriter{
+		meta:     meta,
+		records:  make(chan []byte, o.BufferSize),
+		done:     make(chan struct{}),
+		stop:     make(chan struct{}),
+		fallback: os.Stderr,
+		rate:     o.RateLimit,
+		burst:    float64(o.Burst),
+		tokens:   float64(o.Burst),
+		last:     time.Now(),
+	}
+}
+
+// newRemoteLogWriter returns a writer that sends records to the remoteLogListener at addr
+func newRemoteLogWriter(addr string, meta logRecord, o RemoteLogOptions) *remoteLogWriter {
+	w := newLogWriter(meta, o.withDefaults())
+	go w.sendTCP(addr)
+	return w
+}
+
+// newStorageLogWriter returns a writer that periodically writes batches of records
+// as new files in dir for a remoteLogTailer to read
+func newStorageLogWriter(dir string, meta logRecord, o RemoteLogOptions) *remoteLogWriter {
+	o = o.withDefaults()
+	w := newLogWriter(meta, o)
+	go w.sendStorage(dir, o.FlushInterval, o.BufferSize)
+	return w
+}
+
+func (w *remoteLogWriter) Write(b []byte) (int, error) {
+	msg := strings.TrimRight(string(b), "\n")
+	return w.writeLevel(guessLevel(msg), msg, len(b))
+}
+
+// WriteLevel sends b as a record with the given level
+func (w *remoteLogWriter) WriteLevel(level string, b []byte) (int, error) {
+	return w.writeLevel(level, strings.TrimRight(string(b), "\n"), len(b))
+}
+
+func (w *remoteLogWriter) writeLevel(level, msg string, n int) (int, error) {
+	if w.failed.Load() {
+		fmt.Fprintln(w.fallback, msg)
+		return n, nil
+	}
+	rec := w.meta
+	rec.Time = time.Now()
+	rec.Message = msg
+	rec.Level = level
+	line, err := json.Marshal(rec)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || !w.allow(rec.Time) {
+		w.dropped.Add(1)
+		return n, nil
+	}
+	select {
+	case w.records <- line:
+	default:
+		w.dropped.Add(1)
+	}
+	return n, nil
+}
+
+// allow implements a token bucket rate limit; w.mu must be held
+func (w *remoteLogWriter) allow(now time.Time) bool {
+	w.tokens += now.Sub(w.last).Seconds() * w.rate
+	w.last = now
+	if w.tokens > w.burst {
+		w.tokens = w.burst
+	}
+	if w.tokens < 1 {
+		return false
+	}
+	w.tokens--
+	return true
+}
+
+// remoteLogWriters retry connecting with backoff (buffering records) before
+// writing records to stderr, and then try to reconnect every remoteLogRedialInterval
+var (
+	remoteLogDialAttempts   = 6
+	remoteLogBackoff        = 100 * time.Millisecond
+	remoteLogMaxBackoff     = 5 * time.Second
+	remoteLogRedialInterval = 30 * time.Second
+)
+
+func (w *remoteLogWriter) sendTCP(addr string) {
+	defer close(w.done)
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	redial := time.NewTicker(remoteLogRedialInterval)
+	defer redial.Stop()
+	for {
+		if w.failed.Load() {
+			select {
+			case line, ok := <-w.records:
+				if !ok {
+					return
+				}
+				w.writeFallback(line)
+			case <-redial.C:
+				if c, err := net.DialTimeout("tcp", addr, 5*time.Second); err == nil {
+					fmt.Fprintf(w.fallback, "reconnected to remote logger %s\n", addr)
+					conn = c
+					w.failed.Store(false)
+				}
+			case <-w.stop:
+				return
+			}
+			continue
+		}
+
+		line, ok := <-w.records
+		if !ok {
+			return
+		}
+		var err error
+		if conn, err = w.send(conn, addr, line); err != nil {
+			fmt.Fprintf(w.fallback, "failed connecting to remote logger %s; logging to stderr\n", err)
+			w.writeFallback(line)
+			// write what was buffered before later writes go to fallback directly
+			for drained := false; !drained; {
+				select {
+				case line, ok := <-w.records:
+					if !ok {
+						return
+					}
+					w.writeFallback(line)
+				default:
+					drained = true
+				}
+			}
+			w.failed.Store(true)
+		}
 	}
-	return conn, nil
 }
 
-// listen for log messages, and copy them to stderr
-func startRemoteLogListner() string {
-	tcpAddr, err := net.ResolveTCPAddr("tcp4", "0.0.0.0:0")
+// send writes line to conn, (re)connecting to addr with backoff. Records
+// written meanwhile are buffered in w.records.
+func (w *remoteLogWriter) send(conn net.Conn, addr string, line []byte) (net.Conn, error) {
+	backoff := remoteLogBackoff
+	var err error
+	for attempt := 0; attempt < remoteLogDialAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-w.stop:
+				return nil, err
+			}
+			backoff = min(backoff*2, remoteLogMaxBackoff)
+		}
+		if conn == nil {
+			if conn, err = net.DialTimeout("tcp", addr, 5*time.Second); err != nil {
+				conn = nil
+				continue
+			}
+		}
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second)) // nolint:errcheck
+		if _, err = conn.Write(line); err == nil {
+			return conn, nil
+		}
+		conn.Close()
+		conn = nil
+	}
+	return nil, err
+}
+
+// writeFallback writes the message of a queued record to fallback
+func (w *remoteLogWriter) writeFallback(line []byte) {
+	var rec logRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return
+	}
+	fmt.Fprintln(w.fallback, rec.Message)
+}
+
+func (w *remoteLogWriter) sendStorage(dir string, interval time.Duration, bufferSize int) {
+	defer close(w.done)
+	id := w.meta.Attempt
+	if id == "" {
+		id = fmt.Sprintf("%s-%d", w.meta.Host, os.Getpid())
+	}
+	var seq int
+	var batch bytes.Buffer
+	var lines int
+	flush := func() {
+		if lines == 0 {
+			return
+		}
+		name := fmt.Sprintf("%s/%s-%d-%s-%05d.jsonl", strings.TrimSuffix(dir, "/"), w.meta.Stage, w.meta.Step, id, seq)
+		if err := writeFile(name, batch.Bytes()); err != nil {
+			fmt.Fprintf(os.Stderr, "failed writing remote log %s %s\n", name, err)
+			return
+		}
+		seq++
+		batch.Reset()
+		lines = 0
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-w.records:
+			if !ok {
+				flush()
+				return
+			}
+			if lines >= bufferSize {
+				w.dropped.Add(1)
+				continue
+			}
+			batch.Write(line)
+			lines++
+		case <-ticker.C:
+			flush()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// lookupQuiet returns the FileSystem for name as mrfs.Lookup does, without
+// logging the hadoop commands it runs
+func lookupQuiet(name string) (mrfs.FileSystem, string, error) {
+	fsys, name, err := mrfs.Lookup(name)
+	if _, ok := fsys.(mrfs.Hadoop); ok {
+		fsys = mrfs.Hadoop{Quiet: true}
+	}
+	return fsys, name, err
+}
+
+// writeFile writes data to name without logging; the log package writes to
+// the remoteLogWriter so logging here would add a record for every flush
+func writeFile(name string, data []byte) error {
+	fsys, name, err := lookupQuiet(name)
 	if err != nil {
-		log.Fatalf("error resolving %s", err)
+		return err
 	}
-	ln, err := net.Listen("tcp4", tcpAddr.String())
+	f, err := fsys.Create(context.Background(), name)
 	if err != nil {
-		log.Fatalf("error listening %s", err)
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Close flushes buffered records, waiting up to 5 seconds for delivery
+func (w *remoteLogWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.records)
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(5 * time.Second):
+		close(w.stop)
+		<-w.done
+	}
+	if n := w.dropped.Load(); n > 0 {
+		Counter("gomrjob", "remote log lines dropped", n)
+	}
+	return nil
+}
+
+// remoteLogListener accepts connections from remoteLogWriter's and prints the
+// records they send until closed
+type remoteLogListener struct {
+	ln      net.Listener
+	addr    string
+	printer *remoteLogPrinter
+
+	accepting chan struct{} // closed when accept returns
+
+	mu     sync.Mutex
+	conns  map[net.Conn]bool
+	closed bool
+	wg     sync.WaitGroup // active connections
+}
+
+// startRemoteLogListener listens on listenAddr (default a random port) for log
+// messages, and sends them to p. Tasks are directed to advertiseAddr (default hostname:port)
+func startRemoteLogListener(p *remoteLogPrinter, listenAddr, advertiseAddr string) (*remoteLogListener, error) {
+	if listenAddr == "" {
+		listenAddr = "0.0.0.0:0"
+	}
+	ln, err := net.Listen("tcp4", listenAddr)
+	if err != nil {
+		return nil, err
 	}
 	log.Printf("listening on %v for log messages", ln.Addr())
 
-	go func() {
-		for {
-			conn, err := ln.Accept()
-			if err != nil {
-				// handle error
-				continue
+	if advertiseAddr == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("failed getting hostname %w", err)
+		}
+		advertiseAddr = strings.Replace(ln.Addr().String(), "0.0.0.0", hostname, 1)
+	}
+	l := &remoteLogListener{
+		ln:        ln,
+		addr:      advertiseAddr,
+		printer:   p,
+		conns:     make(map[net.Conn]bool),
+		accepting: make(chan struct{}),
+	}
+	go l.accept()
+	return l, nil
+}
+
+// Addr returns the address tasks should connect to
+func (l *remoteLogListener) Addr() string {
+	return l.addr
+}
+
+func (l *remoteLogListener) accept() {
+	defer close(l.accepting)
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			l.mu.Lock()
+			closed := l.closed
+			l.mu.Unlock()
+			if closed {
+				return
 			}
-			log.Printf("accepted remote logging connection from %s", conn.RemoteAddr())
-			go io.Copy(os.Stderr, conn) // nolint:errcheck
+			time.Sleep(10 * time.Millisecond)
+			continue
 		}
+		l.mu.Lock()
+		l.conns[conn] = true
+		l.wg.Add(1)
+		l.mu.Unlock()
+		go l.handle(conn)
+	}
+}
+
+func (l *remoteLogListener) handle(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		conn.Close()
 	}()
+	r := bufio.NewReaderSize(conn, 1024*1024)
+	for {
+		line, err := r.ReadBytes('\n')
+		// a line without a trailing newline was cut short by a dropped connection
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			l.printer.emitLine(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
 
-	listenAddr := ln.Addr().String()
-	hostname, err := os.Hostname()
-	if err != nil {
-		log.Fatalf("failed getting hostname %s", err)
+// Close stops accepting connections and waits (up to a second) for records in
+// flight from connected tasks
+func (l *remoteLogListener) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	// accept connections already pending before closing the listener
+	l.ln.(*net.TCPListener).SetDeadline(time.Now().Add(100 * time.Millisecond)) // nolint:errcheck
+	<-l.accepting
+	err := l.ln.Close()
+
+	l.mu.Lock()
+	for conn := range l.conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second)) // nolint:errcheck
 	}
+	l.mu.Unlock()
+	l.wg.Wait()
+	l.printer.Close()
+	return err
+}
 
-	return strings.Replace(listenAddr, "0.0.0.0", hostname, 1)
+// remoteLogTailer polls dir for files written by newStorageLogWriter and
+// prints the records in each new file
+type remoteLogTailer struct {
+	dir     string
+	printer *remoteLogPrinter
+	seen    map[string]bool
+	stop    chan struct{}
+	done    chan struct{}
 }
 
-type prefixLogger struct {
-	prefix []byte
-	w      io.Writer
+func startRemoteLogTailer(p *remoteLogPrinter, dir string, interval time.Duration) *remoteLogTailer {
+	t := &remoteLogTailer{
+		dir:     strings.TrimSuffix(dir, "/"),
+		printer: p,
+		seen:    make(map[string]bool),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	log.Printf("polling %s for log messages", t.dir)
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.poll()
+			case <-t.stop:
+				t.poll()
+				return
+			}
+		}
+	}()
+	return t
 }
 
-func (p *prefixLogger) Write(b []byte) (n int, err error) {
-	n, err = p.w.Write(p.prefix)
+func (t *remoteLogTailer) poll() {
+	// polling doesn't log the hadoop commands it runs every PollInterval
+	ctx := context.Background()
+	fsys, dir, err := lookupQuiet(t.dir)
+	if err != nil {
+		log.Printf("failed listing remote logs %s", err)
+		return
+	}
+	files, err := fsys.Glob(ctx, strings.TrimSuffix(dir, "/")+"/*.jsonl")
 	if err != nil {
-		return n, err
+		log.Printf("failed listing remote logs %s", err)
+		return
+	}
+	for _, f := range files {
+		if t.seen[f.Path()] {
+			continue
+		}
+		rc, err := fsys.Open(ctx, f.Path())
+		if err != nil {
+			log.Printf("failed reading remote log %s", err)
+			continue
+		}
+		t.seen[f.Path()] = true
+		r := bufio.NewReaderSize(rc, 1024*1024)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				t.printer.emitLine(line)
+			}
+			if err != nil {
+				break
+			}
+		}
+		rc.Close()
 	}
-	nn, err := p.w.Write(b)
-	return n + nn, err
 }
 
-// NewPrefixLogger returns a writer that behaves like w except
-// that it writes a prefix before each write
-func newPrefixLogger(prefix string, w io.Writer) io.Writer {
-	return &prefixLogger{[]byte(prefix), w}
+// Close checks for new logs a final time and stops polling
+func (t *remoteLogTailer) Close() error {
+	close(t.stop)
+	<-t.done
+	return t.printer.Close()
 }
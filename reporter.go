@@ -1,34 +1,169 @@
 package gomrjob
 
 import (
+	"bytes"
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"syscall"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// CounterFlushInterval is how often counters batched while a task runs are
+// written to hadoop. Counters are always flushed when the task exits.
+var CounterFlushInterval = 10 * time.Second
+
+// reporterOutput is where reporter lines are written
+var reporterOutput io.Writer = os.Stderr
+
+// SetReporterOutput sets where counter and status lines are written (stderr
+// by default) and returns the previous output. It is used by mrtest to collect
+// counters from steps run in process.
+func SetReporterOutput(w io.Writer) io.Writer {
+	registry.writeLock.Lock()
+	defer registry.writeLock.Unlock()
+	prev := reporterOutput
+	reporterOutput = w
+	return prev
+}
+
+// captureMu serializes CaptureReporter
+var captureMu sync.Mutex
+
+// CaptureReporter writes counters and status reported by the process to w
+// rather than the reporter output until the returned flush func is called.
+// Counters are batched as they are while Run executes a task and written to w
+// by flush. Captures are serialized; CaptureReporter blocks until an earlier
+// capture is flushed. It is used by mrtest to collect the counters of each
+// phase of a step run in process.
+func CaptureReporter(w io.Writer) (flush func()) {
+	captureMu.Lock()
+	flushCounters() // counters batched before the capture are not for w
+	prev := SetReporterOutput(w)
+	batching := registry.batching.Load()
+	startCounterBatching()
+	return func() {
+		if batching {
+			flushCounters()
+		} else {
+			stopCounterBatching()
+		}
+		SetReporterOutput(prev)
+		captureMu.Unlock()
+	}
+}
+
 // reporter:counter:<group>,<counter>,<amount>
+//
+// While Run is executing a task, counters are accumulated in process and
+// flushed every CounterFlushInterval. Use NewCounter on hot paths to avoid
+// looking up the counter on each call.
 func Counter(group string, counter string, amount int64) {
-	fmt.Fprintf(os.Stderr, "reporter:counter:%s,%s,%d\n", group, counter, amount)
-	os.Stderr.Sync()
+	if registry.batching.Load() {
+		NewCounter(group, counter).Add(amount)
+		return
+	}
+	writeReporter(fmt.Sprintf("reporter:counter:%s,%s,%d\n", group, counter, amount))
 }
 
 // reporter:status:<message>
 func Status(message string) {
-	fmt.Fprintf(os.Stderr, "reporter:status:%s\n", message)
-	os.Stderr.Sync()
+	writeReporter(fmt.Sprintf("reporter:status:%s\n", message))
+}
+
+func writeReporter(s string) {
+	registry.writeLock.Lock()
+	defer registry.writeLock.Unlock()
+	io.WriteString(reporterOutput, s) // nolint:errcheck
+	if f, ok := reporterOutput.(*os.File); ok {
+		f.Sync()
+	}
+}
+
+// CounterHandle is a hadoop counter that can be incremented without
+// formatting a reporter line on each call
+type CounterHandle struct {
+	group, name string
+	v           atomic.Int64
+}
+
+// NewCounter returns the counter for group and name. Handles are shared, so
+// NewCounter can be called once and the handle reused.
+func NewCounter(group, name string) *CounterHandle {
+	key := group + "," + name
+	if c, ok := registry.counters.Load(key); ok {
+		return c.(*CounterHandle)
+	}
+	c, _ := registry.counters.LoadOrStore(key, &CounterHandle{group: group, name: name})
+	return c.(*CounterHandle)
+}
+
+// Add increments the counter by n
+func (c *CounterHandle) Add(n int64) {
+	if registry.batching.Load() {
+		c.v.Add(n)
+		return
+	}
+	writeReporter(fmt.Sprintf("reporter:counter:%s,%s,%d\n", c.group, c.name, n))
+}
+
+// Inc increments the counter by one
+func (c *CounterHandle) Inc() { c.Add(1) }
+
+var registry struct {
+	counters  sync.Map // "group,name" -> *CounterHandle
+	batching  atomic.Bool
+	writeLock sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// startCounterBatching accumulates counters until stopCounterBatching,
+// flushing them every CounterFlushInterval
+func startCounterBatching() {
+	if !registry.batching.CompareAndSwap(false, true) {
+		return
+	}
+	registry.stop, registry.done = make(chan struct{}), make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(CounterFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flushCounters()
+			case <-stop:
+				return
+			}
+		}
+	}(registry.stop, registry.done)
 }
 
-func auditCpuTime(group string, prefix string) {
-	var u syscall.Rusage
-	err := syscall.Getrusage(syscall.RUSAGE_SELF, &u)
-	if err != nil {
-		log.Printf("error getting Rusage: %s", err)
+// stopCounterBatching flushes any batched counters; later increments are
+// written immediately
+func stopCounterBatching() {
+	if !registry.batching.CompareAndSwap(true, false) {
 		return
 	}
-	userTime := time.Duration(u.Utime.Nano()) * time.Nanosecond
-	systemTime := time.Duration(u.Stime.Nano()) * time.Nanosecond
-	Counter(group, fmt.Sprintf("%s userTime (ms)", prefix), int64(userTime/time.Millisecond))
-	Counter(group, fmt.Sprintf("%s systemTime (ms)", prefix), int64(systemTime/time.Millisecond))
+	close(registry.stop)
+	<-registry.done
+	flushCounters()
+}
+
+// flushCounters writes one reporter line for each counter incremented since
+// the last flush
+func flushCounters() {
+	var b bytes.Buffer
+	registry.counters.Range(func(_, v any) bool {
+		c := v.(*CounterHandle)
+		if n := c.v.Swap(0); n != 0 {
+			fmt.Fprintf(&b, "reporter:counter:%s,%s,%d\n", c.group, c.name, n)
+		}
+		return true
+	})
+	if b.Len() > 0 {
+		writeReporter(b.String())
+	}
 }
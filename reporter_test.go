@@ -0,0 +1,57 @@
+package gomrjob
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterBatching(t *testing.T) {
+	var out bytes.Buffer
+	defer SetReporterOutput(SetReporterOutput(&out))
+
+	Counter("g", "unbatched", 1)
+	assert.Equal(t, out.String(), "reporter:counter:g,unbatched,1\n")
+	out.Reset()
+
+	startCounterBatching()
+	c := NewCounter("g", "lines")
+	assert.Equal(t, NewCounter("g", "lines"), c)
+	for i := 0; i < 100; i++ {
+		c.Inc()
+	}
+	Counter("g", "lines", 5)
+	Counter("g", "errors", 2)
+	assert.Equal(t, out.String(), "")
+
+	flushCounters()
+	assert.Contains(t, out.String(), "reporter:counter:g,lines,105\n")
+	assert.Contains(t, out.String(), "reporter:counter:g,errors,2\n")
+	out.Reset()
+
+	c.Add(3)
+	stopCounterBatching()
+	assert.Equal(t, out.String(), "reporter:counter:g,lines,3\n")
+	out.Reset()
+
+	c.Inc()
+	assert.Equal(t, out.String(), "reporter:counter:g,lines,1\n")
+}
+
+func TestCaptureReporter(t *testing.T) {
+	var out, captured bytes.Buffer
+	defer SetReporterOutput(SetReporterOutput(&out))
+
+	flush := CaptureReporter(&captured)
+	c := NewCounter("g", "captured")
+	c.Inc()
+	c.Inc()
+	Status("working")
+	assert.Equal(t, captured.String(), "reporter:status:working\n")
+	flush()
+	assert.Equal(t, captured.String(), "reporter:status:working\nreporter:counter:g,captured,2\n")
+
+	c.Inc()
+	assert.Equal(t, out.String(), "reporter:counter:g,captured,1\n")
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/jehiah/gomrjob/hdfs"
 	"github.com/jehiah/gomrjob/internal/gcloud"
 	"github.com/jehiah/gomrjob/internal/storage"
+	"github.com/jehiah/gomrjob/mrfs"
 )
 
 var (
@@ -27,6 +29,7 @@ var (
 	stage        = flag.String("stage", "", "map,reduce")
 	step         = flag.Int("step", 0, "the step to execute")
 	remoteLogger = flag.String("remote-logger", "", "address for remote logger")
+	remoteLogDir = flag.String("remote-log-dir", "", "path to write remote logs")
 
 	// flags for Dataproc support
 	bucket         = flag.String("bucket", "", "Google Storage bucket to use | GS_BUCKET")
@@ -58,10 +61,30 @@ type Runner struct {
 	Files              []string          // -file
 	Properties         map[string]string // -D key=value argumets to mapreduce-streaming.jar
 	JobType            JobType
+	Backend            Backend        // when set, used to run jobs instead of JobType
+	InputCheck         InputCheck     // how missing InputFiles are handled; unchecked by default
+	ReducerSizing      *ReducerSizing // when set, sizes reducers for each step from the size of its input
+	RemoteLog          RemoteLogOptions
+	Progress           ProgressListener // defaults to NewProgressLogger(os.Stderr)
+	Hooks              Hooks
+	Metrics            *MetricsExporter // when set, exports counters and timings after each step
+
+	// Heartbeat is how often tasks report their status to hadoop, which keeps
+	// tasks that are slow to write output from timing out. Disabled when 0.
+	Heartbeat time.Duration
+
+	// ResourceCounters reports detailed resource usage counters for each task
+	// (faults, IO, context switches, GC and records by protocol). By default
+	// only tasks, wallTime, userTime and maxRSS are reported, as hadoop limits
+	// the number of counters in a job (mapreduce.job.counters.max, 120 by default).
+	ResourceCounters bool
 
 	defaultProto string
+	inputs       *InputSummary
+	inputFiles   []string // InputFiles without missing patterns (InputCheckWarn)
 	tmpPath      string
 	gcloud       *http.Client
+	staged       []string // cache files uploaded by the current Run
 }
 
 // LoadAndValidateFlags loads flags from env and checks for missing arguments
@@ -117,6 +140,9 @@ func (r *Runner) setTempPath() {
 }
 
 func (r *Runner) Cleanup() error {
+	if r.Backend != nil {
+		return mrfs.RemoveAll(context.Background(), r.defaultProto+r.tmpPath)
+	}
 	switch r.JobType {
 	case HDFS:
 		return hdfs.RMR(r.tmpPath)
@@ -126,10 +152,10 @@ func (r *Runner) Cleanup() error {
 	panic("invalid job type")
 }
 
-// submitJob runs a single map/combine/reduce job.
-func (r *Runner) submitJob(loggerAddress string, stepNumber int, step Step) error {
+// newJob returns the map/combine/reduce job for a step
+func (r *Runner) newJob(logArgs []string, stepNumber int, step Step) (hdfs.Job, error) {
 	if stepNumber >= len(r.Steps) || len(r.Steps) == 0 {
-		return fmt.Errorf("step %d out of range", stepNumber)
+		return hdfs.Job{}, fmt.Errorf("step %d out of range", stepNumber)
 	}
 	var input []string
 	var output string
@@ -146,14 +172,15 @@ func (r *Runner) submitJob(loggerAddress string, stepNumber int, step Step) erro
 
 	if stepNumber == 0 {
 		input = r.InputFiles
+		if r.inputFiles != nil {
+			input = r.inputFiles
+		}
 	} else {
 		input = append(input, fmt.Sprintf("%s/step_%d/output/part-*", r.tmpPath, stepNumber-1))
 	}
 
 	taskOptions := append([]string{executibleName}, r.PassThroughOptions...)
-	if loggerAddress != "" {
-		taskOptions = append(taskOptions, fmt.Sprintf("--remote-logger=%s", loggerAddress))
-	}
+	taskOptions = append(taskOptions, logArgs...)
 	taskOptions = append(taskOptions, fmt.Sprintf("--step=%d", stepNumber))
 	taskString := strings.Join(taskOptions, " ")
 
@@ -167,35 +194,80 @@ func (r *Runner) submitJob(loggerAddress string, stepNumber int, step Step) erro
 		name = fmt.Sprintf("%s-step_%d", name, stepNumber)
 	}
 
-	// StepReducerTasksCount interface overrides reducer tasks per step
-	reducerTasks := r.ReducerTasks
-	if step, ok := step.(StepReducerTasksCount); ok {
-		reducerTasks = step.NumberReducerTasks()
-	}
-
 	j := hdfs.Job{
 		Name:         name,
-		ReducerTasks: reducerTasks,
+		ReducerTasks: r.reducerTasks(stepNumber, step),
 		Input:        input,
 		Output:       output,
 		Mapper:       fmt.Sprintf("%s --stage=mapper", taskString),
 		Reducer:      fmt.Sprintf("%s --stage=reducer", taskString),
 		Files:        r.Files,
 		Properties:   r.Properties,
-		CacheFiles:   r.CacheFiles,
+		CacheFiles:   append(r.CacheFiles[:len(r.CacheFiles):len(r.CacheFiles)], r.staged...),
 		DefaultProto: r.defaultProto,
 	}
+	if r.Backend == nil && r.JobType == Dataproc {
+		j.Files = nil // uploaded as cache files
+	}
 	if _, ok := step.(Combiner); ok {
 		j.Combiner = fmt.Sprintf("%s --stage=combiner", taskString)
 	}
-	switch r.JobType {
-	case HDFS:
-		return hdfs.SubmitJob(j)
-	case Dataproc:
-		return dataproc.SubmitJob(j, r.gcloud, *project, *region, *cluster)
+	return j, nil
+}
+
+// submitJob runs a single map/combine/reduce job, calling the BeforeStep,
+// AfterStep and StepFailed hooks.
+func (r *Runner) submitJob(logArgs []string, stepNumber int, step Step) StepEvent {
+	e := StepEvent{Step: stepNumber, Start: time.Now()}
+	e.Job, e.Err = r.newJob(logArgs, stepNumber, step)
+	if e.Err == nil && r.Hooks.BeforeStep != nil {
+		e.Err = r.Hooks.BeforeStep(&e)
+	}
+	if e.Err != nil {
+		return e
+	}
+
+	progress := r.Progress
+	if progress == nil {
+		progress = NewProgressLogger(os.Stderr)
+	}
+	e.Job.OnProgress = func(p hdfs.Progress) {
+		e.Progress = p
+		progress.StepProgress(StepProgress{Step: stepNumber, Steps: len(r.Steps), Name: e.Job.Name, Progress: p})
+	}
+	e.Job.OnCounters = func(c hdfs.Counters) {
+		e.Counters = c
+	}
+	switch {
+	case r.Backend != nil:
+		e.Err = r.Backend.Submit(context.Background(), e.Job, step)
+	case r.JobType == HDFS:
+		e.Err = hdfs.SubmitJob(e.Job)
+	case r.JobType == Dataproc:
+		e.Err = dataproc.SubmitJob(e.Job, r.gcloud, *project, *region, *cluster)
 	default:
 		panic("unknown job type")
 	}
+	e.Duration = time.Since(e.Start)
+	if e.Counters != nil {
+		e.Resources = StepResources(e.Counters, stepNumber)
+		var stages []string
+		for stage := range e.Resources {
+			stages = append(stages, stage)
+		}
+		sort.Strings(stages)
+		for _, stage := range stages {
+			log.Printf("step %d %s: %s", stepNumber, stage, e.Resources[stage])
+		}
+	}
+
+	if e.Err != nil && r.Hooks.StepFailed != nil {
+		r.Hooks.StepFailed(e)
+	}
+	if e.Err == nil && r.Hooks.AfterStep != nil {
+		r.Hooks.AfterStep(e)
+	}
+	return e
 }
 
 func (r *Runner) copyRunningBinaryToHdfs() error {
@@ -208,7 +280,7 @@ func (r *Runner) copyRunningBinaryToHdfs() error {
 	if err := hdfs.Put(localExePath, exePath); err != nil {
 		return fmt.Errorf("error copying %s to hdfs %s", exePath, err)
 	}
-	r.CacheFiles = append(r.CacheFiles, fmt.Sprintf("%s%s#%s", r.defaultProto, exePath, executibleName))
+	r.staged = append(r.staged, fmt.Sprintf("%s%s#%s", r.defaultProto, exePath, executibleName))
 	return nil
 }
 
@@ -227,7 +299,7 @@ func (r *Runner) cacheFileInGoogleStorage(ctx context.Context, src, target strin
 		return err
 	}
 
-	r.CacheFiles = append(r.CacheFiles, cachedFile)
+	r.staged = append(r.staged, cachedFile)
 	return nil
 }
 
@@ -236,6 +308,56 @@ func (r *Runner) copyRunningBinaryToDataproc(ctx context.Context) error {
 	return r.cacheFileInGoogleStorage(ctx, "/proc/self/exe", exePath)
 }
 
+// startRemoteLogs starts receiving task logs per RemoteLog.Transport. It returns
+// the arguments that direct tasks where to send logs, and a Closer to stop
+// receiving them (nil if remote logging is disabled)
+func (r *Runner) startRemoteLogs(out io.Writer) ([]string, io.Closer) {
+	o := r.RemoteLog.withDefaults()
+	transport := o.Transport
+	if transport == RemoteLogAuto {
+		switch {
+		case r.Backend != nil:
+			transport = RemoteLogDisabled
+		case r.JobType == Dataproc:
+			transport = RemoteLogStorage
+		default:
+			transport = RemoteLogTCP
+		}
+	}
+	switch transport {
+	case RemoteLogTCP:
+		ln, err := startRemoteLogListener(newRemoteLogPrinter(out, o.Filter), o.ListenAddr, o.AdvertiseAddr)
+		if err != nil {
+			log.Printf("remote logging disabled: %s", err)
+			return nil, nil
+		}
+		return []string{fmt.Sprintf("--remote-logger=%s", ln.Addr())}, ln
+	case RemoteLogStorage:
+		dir := fmt.Sprintf("%s%s/logs", r.defaultProto, r.tmpPath)
+		return []string{fmt.Sprintf("--remote-log-dir=%s", dir)}, startRemoteLogTailer(newRemoteLogPrinter(out, o.Filter), dir, o.PollInterval)
+	}
+	return nil, nil
+}
+
+// remoteLogWriter returns a writer for task logs when the --remote-logger or --remote-log-dir flag is set
+func (r *Runner) remoteLogWriter() *remoteLogWriter {
+	switch {
+	case *remoteLogger != "":
+		return newRemoteLogWriter(*remoteLogger, newTaskLogRecord(), r.RemoteLog)
+	case *remoteLogDir != "":
+		if strings.HasPrefix(*remoteLogDir, "gs://") {
+			client, err := gcloud.DefaultClient(gcloud.ScopeStorageReadWrite)
+			if err != nil {
+				log.Printf("failed loading Google credentials for remote logging %s", err)
+				return nil
+			}
+			mrfs.Register("gs", mrfs.GoogleStorage{Client: client})
+		}
+		return newStorageLogWriter(*remoteLogDir, newTaskLogRecord(), r.RemoteLog)
+	}
+	return nil
+}
+
 // return which stage the runner is executing as
 func (r *Runner) Stage() string {
 	switch *stage {
@@ -256,18 +378,20 @@ func (r *Runner) Run() error {
 	if *step >= len(r.Steps) {
 		return fmt.Errorf("invalid --step=%d (max %d)", *step, len(r.Steps))
 	}
-	if *remoteLogger != "" {
-		conn, err := dialRemoteLogger(*remoteLogger)
-		if err != nil {
-			if *stage == "" {
-				Status(fmt.Sprintf("error dialing remote logger %s", err))
-			} else {
-				log.Printf("failed connecting to remote logger %s", err)
-			}
-		} else {
-			hostname, _ := os.Hostname()
-			w := newPrefixLogger(fmt.Sprintf("[%s %s:%d] ", hostname, *stage, *step), conn)
-			log.SetOutput(w)
+	taskStart := time.Now()
+	var remoteLog *remoteLogWriter
+	var hb *heartbeat
+	if *stage != "" {
+		startCounterBatching()
+		defer stopCounterBatching()
+		if r.Heartbeat > 0 {
+			hb = startHeartbeat(fmt.Sprintf("%s[%d]", *stage, *step), r.Heartbeat)
+			defer hb.Stop()
+		}
+		remoteLog = r.remoteLogWriter()
+		if remoteLog != nil {
+			log.SetOutput(remoteLog)
+			setTaskLogOutput(remoteLog)
 		}
 	}
 	s := r.Steps[*step]
@@ -295,39 +419,82 @@ func (r *Runner) Run() error {
 		err = s.Combiner(os.Stdin, os.Stdout)
 	}
 	if *stage != "" {
-		auditCpuTime("gomrjob", fmt.Sprintf("%s[%d]", *stage, *step))
+		if hb != nil {
+			hb.Stop()
+		}
+		auditResources("gomrjob", fmt.Sprintf("%s[%d]", *stage, *step), taskStart, r.ResourceCounters)
 		if err != nil {
 			log.Printf("Error: %s", err)
+		}
+		if remoteLog != nil {
+			log.SetOutput(os.Stderr)
+			setTaskLogOutput(os.Stderr)
+			remoteLog.Close()
+		}
+		stopCounterBatching()
+		if err != nil {
 			os.Exit(1)
-		} else {
-			os.Exit(0)
 		}
+		os.Exit(0)
 		return nil
 	}
-	if !*submitJob {
+	if !*submitJob && r.Backend == nil {
 		return errors.New("missing --submit-job")
 	}
 
 	r.setTempPath()
-	LoadAndValidateFlags()
-	if *serviceAccount != "" {
+	if r.Backend != nil {
+		r.defaultProto = r.Backend.DefaultProto()
+	} else {
+		LoadAndValidateFlags()
+	}
+	if *serviceAccount != "" && r.Backend == nil {
 		r.gcloud, err = gcloud.LoadFromServiceJSON(*serviceAccount, gcloud.ScopeCloudPlatform, gcloud.ScopeStorageReadWrite)
 		if err != nil {
 			log.Fatal(err)
 		}
 		r.JobType = Dataproc
 		r.defaultProto = fmt.Sprintf("gs://%s/", *bucket)
+		mrfs.Register("gs", mrfs.GoogleStorage{Client: r.gcloud})
 	}
 
-	switch r.JobType {
-	case HDFS:
+	result := RunResult{Start: time.Now()}
+	result.Err = r.submitSteps(&result)
+	result.Duration = time.Since(result.Start)
+	if r.Hooks.Completed != nil {
+		r.Hooks.Completed(result)
+	}
+	return result.Err
+}
+
+// submitSteps uploads the running binary and submits each step in order
+func (r *Runner) submitSteps(result *RunResult) error {
+	if err := r.checkInputs(); err != nil {
+		return err
+	}
+
+	if r.Hooks.BeforeUpload != nil {
+		if err := r.Hooks.BeforeUpload(r); err != nil {
+			return err
+		}
+	}
+
+	r.staged = nil
+	switch {
+	case r.Backend != nil:
+		exePath := fmt.Sprintf("%s/%s", r.tmpPath, executibleName)
+		if err := r.Backend.Stage(context.Background(), "/proc/self/exe", exePath); err != nil {
+			return err
+		}
+		r.staged = append(r.staged, fmt.Sprintf("%s%s#%s", r.defaultProto, exePath, executibleName))
+	case r.JobType == HDFS:
 		if err := hdfs.FsCmd("-mkdir", "-p", r.defaultProto+r.tmpPath); err != nil {
 			return err
 		}
 		if err := r.copyRunningBinaryToHdfs(); err != nil {
 			return err
 		}
-	case Dataproc:
+	case r.JobType == Dataproc:
 		ctx := context.Background()
 		if err := r.copyRunningBinaryToDataproc(ctx); err != nil {
 			return err
@@ -340,18 +507,27 @@ func (r *Runner) Run() error {
 				return err
 			}
 		}
-		r.Files = []string{}
 	}
 
-	loggerAddress := startRemoteLogListner()
+	logArgs, logs := r.startRemoteLogs(os.Stderr)
+	if logs != nil {
+		defer logs.Close()
+	}
 
 	if r.Output == "" {
 		r.Output = fmt.Sprintf("%s%s/output", r.defaultProto, r.tmpPath)
 	}
 
 	for stepNumber, step := range r.Steps {
-		if err := r.submitJob(loggerAddress, stepNumber, step); err != nil {
-			return fmt.Errorf("failed running Step %d = %s", stepNumber, err)
+		e := r.submitJob(logArgs, stepNumber, step)
+		result.Steps = append(result.Steps, e)
+		if r.Metrics != nil {
+			if err := r.Metrics.Export(r.Name, result.Steps); err != nil {
+				log.Printf("failed exporting metrics %s", err)
+			}
+		}
+		if e.Err != nil {
+			return fmt.Errorf("failed running Step %d = %w", stepNumber, e.Err)
 		}
 	}
 
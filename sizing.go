@@ -0,0 +1,125 @@
+package gomrjob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jehiah/gomrjob/dataproc"
+	"github.com/jehiah/gomrjob/hdfs"
+	"github.com/jehiah/gomrjob/internal/gcloud"
+	"github.com/jehiah/gomrjob/mrfs"
+)
+
+// ReducerSizing picks the number of reducers for each step from the size of
+// its input: the expanded InputFiles for the first step, and the measured
+// output of the previous step for later steps.
+type ReducerSizing struct {
+	BytesPerReducer int64
+	Min             int // defaults to 1
+	Max             int // 0 for no limit
+
+	// ClusterCapacity (optional) further limits reducers to the
+	// capacity of the cluster. See YARNCapacity and DataprocCapacity
+	ClusterCapacity CapacityFunc
+}
+
+// CapacityFunc returns the number of tasks a cluster can run concurrently
+type CapacityFunc func() (int, error)
+
+// Reducers returns the number of reducers for inputBytes of input
+func (s ReducerSizing) Reducers(inputBytes int64) int {
+	n := 1
+	if s.BytesPerReducer > 0 {
+		n = int((inputBytes + s.BytesPerReducer - 1) / s.BytesPerReducer)
+	}
+	if n < s.Min {
+		n = s.Min
+	}
+	if s.Max > 0 && n > s.Max {
+		n = s.Max
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// reducerTasks returns the number of reducers for a step. The
+// StepReducerTasksCount interface overrides reducer tasks per step, otherwise
+// ReducerSizing is applied (if set) with a fallback to ReducerTasks.
+func (r *Runner) reducerTasks(stepNumber int, step Step) int {
+	if step, ok := step.(StepReducerTasksCount); ok {
+		return step.NumberReducerTasks()
+	}
+	if r.ReducerSizing == nil {
+		return r.ReducerTasks
+	}
+	var inputBytes int64
+	if stepNumber == 0 {
+		if r.inputs == nil {
+			return r.ReducerTasks
+		}
+		inputBytes = r.inputs.Bytes
+	} else {
+		prev := hdfs.AbsolutePath(fmt.Sprintf("%s/step_%d/output/part-*", r.tmpPath, stepNumber-1), r.defaultProto)
+		files, err := mrfs.Glob(context.Background(), prev)
+		if err != nil {
+			log.Printf("failed measuring output of step %d %s", stepNumber-1, err)
+			return r.ReducerTasks
+		}
+		for _, f := range files {
+			inputBytes += f.Size()
+		}
+	}
+	n := r.ReducerSizing.Reducers(inputBytes)
+	if r.ReducerSizing.ClusterCapacity != nil {
+		capacity, err := r.ReducerSizing.ClusterCapacity()
+		if err != nil {
+			log.Printf("failed getting cluster capacity %s", err)
+		} else if capacity > 0 && n > capacity {
+			n = capacity
+		}
+	}
+	log.Printf("step %d using %d reducers for %s of input", stepNumber, n, formatBytes(inputBytes))
+	return n
+}
+
+// YARNCapacity returns the total vcores reported by the YARN ResourceManager
+// REST API at address (i.e. "http://resourcemanager:8088")
+func YARNCapacity(address string) CapacityFunc {
+	return func() (int, error) {
+		resp, err := http.Get(address + "/ws/v1/cluster/metrics")
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return 0, fmt.Errorf("got status code %d from %s", resp.StatusCode, address)
+		}
+		var m struct {
+			ClusterMetrics struct {
+				TotalVirtualCores int `json:"totalVirtualCores"`
+			} `json:"clusterMetrics"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+			return 0, err
+		}
+		return m.ClusterMetrics.TotalVirtualCores, nil
+	}
+}
+
+// DataprocCapacity returns the vCPUs of the worker nodes of the Dataproc
+// cluster set by --cluster (or $GS_CLUSTER)
+func DataprocCapacity() CapacityFunc {
+	return func() (int, error) {
+		LoadAndValidateFlags()
+		client, err := gcloud.LoadFromServiceJSON(*serviceAccount, gcloud.ScopeCloudPlatform)
+		if err != nil {
+			return 0, err
+		}
+		return dataproc.ClusterVCPUs(client, *project, *region, *cluster)
+	}
+}
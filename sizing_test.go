@@ -0,0 +1,61 @@
+package gomrjob
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReducerSizing(t *testing.T) {
+	type testCase struct {
+		sizing ReducerSizing
+		bytes  int64
+		expect int
+	}
+	tests := []testCase{
+		{ReducerSizing{BytesPerReducer: 100}, 0, 1},
+		{ReducerSizing{BytesPerReducer: 100}, 101, 2},
+		{ReducerSizing{BytesPerReducer: 100, Max: 5}, 10000, 5},
+		{ReducerSizing{BytesPerReducer: 100, Min: 3}, 10, 3},
+	}
+	for i, tc := range tests {
+		got := tc.sizing.Reducers(tc.bytes)
+		if got != tc.expect {
+			t.Errorf("test[%d] got %d expected %d", i, got, tc.expect)
+		}
+	}
+}
+
+type fixedReducers struct{ Step }
+
+func (fixedReducers) NumberReducerTasks() int { return 7 }
+
+func TestStepReducerTasks(t *testing.T) {
+	dir := t.TempDir()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, `{"clusterMetrics":{"totalVirtualCores":4}}`)
+	}))
+	defer srv.Close()
+
+	r := NewRunner()
+	r.defaultProto = "file:///"
+	r.tmpPath = dir
+	r.inputs = &InputSummary{Bytes: 1000}
+	r.ReducerSizing = &ReducerSizing{BytesPerReducer: 100, ClusterCapacity: YARNCapacity(srv.URL)}
+
+	// step 0 is sized from the input and capped by the cluster
+	assert.Equal(t, r.reducerTasks(0, nil), 4)
+
+	// step 1 is sized from the output of step 0
+	p := filepath.Join(dir, "step_0", "output", "part-00000")
+	assert.Equal(t, os.MkdirAll(filepath.Dir(p), 0755), nil)
+	assert.Equal(t, os.WriteFile(p, make([]byte, 150), 0644), nil)
+	assert.Equal(t, r.reducerTasks(1, nil), 2)
+
+	assert.Equal(t, r.reducerTasks(1, fixedReducers{}), 7)
+}